@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+)
+
+// timeRange is part of a day, in minutes since midnight
+type timeRange struct {
+	start, end int
+}
+
+// availability says which slots deadlines can be worked on in, with every slot
+// available if nothing is set
+type availability struct {
+	set       bool
+	days      [7][]timeRange
+	overrides map[string][]timeRange
+}
+
+// overrideKey identifies the date an override is for
+const overrideKey = "2006-01-02"
+
+// newAvailability reads a types.Availability, returning every problem with it
+func newAvailability(a types.Availability) (av availability, problems []error) {
+	byDay := map[time.Weekday][]string{
+		time.Monday:    a.Monday,
+		time.Tuesday:   a.Tuesday,
+		time.Wednesday: a.Wednesday,
+		time.Thursday:  a.Thursday,
+		time.Friday:    a.Friday,
+		time.Saturday:  a.Saturday,
+		time.Sunday:    a.Sunday,
+	}
+	for day, ranges := range byDay {
+		if ranges == nil {
+			ranges = a.Default
+		}
+		if ranges != nil {
+			av.set = true
+		}
+		var rangeProblems []error
+		av.days[day], rangeProblems = parseTimeRanges(ranges, ErrInvalidAvailability)
+		problems = append(problems, rangeProblems...)
+	}
+	av.overrides = map[string][]timeRange{}
+	for _, override := range a.Overrides {
+		av.set = true
+		ranges, rangeProblems := parseTimeRanges(override.Hours, ErrInvalidAvailability)
+		problems = append(problems, rangeProblems...)
+		av.overrides[override.Date.Format(overrideKey)] = ranges
+	}
+	return av, problems
+}
+
+// parseTimeRanges reads ranges such as "09:00-17:30", which can end at "24:00", wrapping
+// invalid around any problems
+func parseTimeRanges(texts []string, invalid error) (ranges []timeRange, problems []error) {
+	for _, text := range texts {
+		var startHour, startMinute, endHour, endMinute int
+		_, err := fmt.Sscanf(text, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute)
+		start, end := startHour*60+startMinute, endHour*60+endMinute
+		if err != nil || startMinute < 0 || startMinute > 59 || endMinute < 0 || endMinute > 59 || start < 0 || end > 24*60 || start >= end {
+			problems = append(problems, fmt.Errorf("%w: %q is not a range such as 09:00-17:30", invalid, text))
+			continue
+		}
+		ranges = append(ranges, timeRange{start: start, end: end})
+	}
+	return ranges, problems
+}
+
+// available says whether the whole of the slot from start to end falls in one of the ranges
+// for the day it starts on
+func (av availability) available(start, end time.Time) bool {
+	if !av.set {
+		return true
+	}
+	ranges, ok := av.overrides[start.Format(overrideKey)]
+	if !ok {
+		ranges = av.days[start.Weekday()]
+	}
+	for _, r := range ranges {
+		// going by the minutes of the day keeps to the clock when daylight saving changes
+		rangeStart := time.Date(start.Year(), start.Month(), start.Day(), 0, r.start, 0, 0, start.Location())
+		rangeEnd := time.Date(start.Year(), start.Month(), start.Day(), 0, r.end, 0, 0, start.Location())
+		if !start.Before(rangeStart) && !end.After(rangeEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+// fillWithAvailability marks the slots deadlines cannot be worked on in as off
+func fillWithAvailability(g grid, timetable []timetableElement) {
+	for i := range timetable {
+		if !g.availability.available(g.slotStart(i), g.slotStart(i+1)) {
+			timetable[i].off = true
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailability(t *testing.T) {
+	av, problems := newAvailability(types.Availability{
+		Default:   []string{"09:00-17:00"},
+		Saturday:  []string{},
+		Sunday:    []string{"22:00-24:00"},
+		Overrides: []types.AvailabilityOverride{{Date: time.Date(2030, 1, 8, 0, 0, 0, 0, time.UTC)}},
+	})
+	assert.Empty(t, problems)
+	slot := func(day, hour, minute int) bool {
+		start := time.Date(2030, 1, day, hour, minute, 0, 0, time.UTC)
+		return av.available(start, start.Add(30*time.Minute))
+	}
+	// Jan 7 2030 is a Monday
+	assert.True(t, slot(7, 9, 0))
+	assert.True(t, slot(7, 16, 30))
+	assert.False(t, slot(7, 16, 45))
+	assert.False(t, slot(7, 8, 45))
+	assert.False(t, slot(8, 10, 0))
+	assert.False(t, slot(12, 10, 0))
+	assert.True(t, slot(13, 23, 30))
+
+	// nothing set leaves every slot available
+	none, problems := newAvailability(types.Availability{})
+	assert.Empty(t, problems)
+	assert.True(t, none.available(time.Date(2030, 1, 7, 3, 0, 0, 0, time.UTC), time.Date(2030, 1, 7, 3, 30, 0, 0, time.UTC)))
+
+	_, problems = newAvailability(types.Availability{Monday: []string{"17:00-09:00", "9-5", "09:00-24:30"}})
+	assert.Len(t, problems, 3)
+	for _, problem := range problems {
+		assert.ErrorIs(t, problem, ErrInvalidAvailability)
+	}
+}
+
+func TestGenerateAvailability(t *testing.T) {
+	now := time.Date(2030, 1, 7, 16, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines:    []Deadline{{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 75, DeadlineTime: now.Add(18 * time.Hour)}}},
+		Availability: types.Availability{Default: []string{"09:00-17:00"}},
+	}
+	timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: Spread})
+	assert.NoError(t, err)
+	// work happens in the last hour of Monday and the first of Tuesday, and never overnight
+	var worked []time.Time
+	for _, slot := range timetable.Slots {
+		switch {
+		case slot.Start.Hour() >= 17 || slot.Start.Hour() < 9:
+			assert.Equal(t, OffSlot, slot.Kind, slot.Start)
+		case slot.Kind == DeadlineSlot:
+			worked = append(worked, slot.Start)
+		}
+	}
+	assert.Len(t, worked, 3)
+	assert.Contains(t, timetable.String(), "Jan 7 17:00-Jan 7 17:30: OFF\n")
+
+	// there are only 2 available slots before an earlier deadline
+	in.Deadlines[0].DeadlineTime = now.Add(17 * time.Hour)
+	_, err = Generate(context.Background(), in, Options{Now: now, Strategy: Spread})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+}
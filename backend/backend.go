@@ -1,13 +1,95 @@
 package backend
 
 import (
-	"math/rand"
 	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+)
+
+// Options control how a timetable is generated
+type Options struct {
+	// Slots is the minimum number of slots in the timetable, which otherwise runs
+	// until the last deadline or event
+	Slots int
+	// Now is the time to plan from, defaulting to the current time
+	Now time.Time
+	// Seed, if set, seeds the random assignment of deadlines to slots, which otherwise uses
+	// one derived from the time the timetable starts
+	Seed *int64
+	// Durations override those in the settings of the input, where set
+	Durations Durations
+	// Strategy chooses how deadlines are assigned to slots, defaulting to Stochastic
+	Strategy Strategy
+	// MaxAttempts and Timeout bound the passes and time the Stochastic strategy takes
+	// before it falls back to Spread, defaulting to DefaultMaxAttempts and DefaultTimeout
+	MaxAttempts int
+	Timeout     time.Duration
+	// BestEffort generates a timetable even when there is too little time for every deadline,
+	// sharing out the time there is by Policy, which defaults to EarliestDeadlineFirst
+	BestEffort bool
+	Policy     Policy
+	// SwitchPenalty and MaxMinutesPerDay override those in the settings of the input, where set
+	SwitchPenalty    float64
+	MaxMinutesPerDay int
+	// Previous, if set, is the state of the last timetable, whose work on deadlines in the
+	// slots starting within FreezeHorizon is kept where it can be, overriding the settings
+	// where set, so only the rest is planned again
+	Previous      *State
+	FreezeHorizon time.Duration
+}
+
+// defaults bounding the Stochastic strategy
+const (
+	DefaultMaxAttempts = 10000
+	DefaultTimeout     = 10 * time.Second
 )
 
-// global variable to store the current time
-var currentTime time.Time = roundUp(time.Now())
+// now is the time to plan from
+func (opts Options) now() time.Time {
+	if opts.Now.IsZero() {
+		return time.Now()
+	}
+	return opts.Now
+}
+
+// seed is the seed to use for a timetable starting at start
+func (opts Options) seed(start time.Time) int64 {
+	if opts.Seed == nil {
+		return start.Unix()
+	}
+	return *opts.Seed
+}
+
+// maxAttempts is the number of passes the Stochastic strategy can make
+func (opts Options) maxAttempts() int {
+	if opts.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+	return opts.MaxAttempts
+}
+
+// switchPenalty is how much less likely work is to switch deadline, from the settings
+// unless overridden
+func (opts Options) switchPenalty(settings types.Settings) float64 {
+	if opts.SwitchPenalty == 0 {
+		return settings.SwitchPenalty
+	}
+	return opts.SwitchPenalty
+}
+
+// freezeHorizon is how far ahead work planned before is kept, from the settings unless
+// overridden
+func (opts Options) freezeHorizon(settings types.Settings) time.Duration {
+	if opts.FreezeHorizon == 0 {
+		return time.Duration(settings.FreezeMinutes) * time.Minute
+	}
+	return opts.FreezeHorizon
+}
 
-func init() {
-	rand.Seed(currentTime.Unix())
-}
\ No newline at end of file
+// timeout is how long the Stochastic strategy can take
+func (opts Options) timeout() time.Duration {
+	if opts.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return opts.Timeout
+}
@@ -0,0 +1,211 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Policy names a way of sharing out too little time between deadlines in a best-effort timetable
+type Policy string
+
+const (
+	// EarliestDeadlineFirst gives each deadline as much time as it can, in the order they are due
+	EarliestDeadlineFirst Policy = "edf"
+	// ByPriority gives each deadline as much time as it can, from the highest priority down,
+	// with deadlines of the same priority in the order they are due
+	ByPriority Policy = "priority"
+	// Proportional gives every deadline the same share of the work it needs, as far as possible
+	Proportional Policy = "proportional"
+)
+
+// allocator works out how many slots each deadline gets, without going over the slots
+// available before any deadline
+type allocator func(deadlines []deadline) []int
+
+// allocators makes the allocation for each policy
+var allocators = map[Policy]allocator{
+	EarliestDeadlineFirst: allocateEarliestFirst,
+	ByPriority:            allocateByPriority,
+	Proportional:          allocateProportionally,
+}
+
+// Policies lists the policies that can be chosen
+func Policies() []Policy {
+	policies := make([]Policy, 0, len(allocators))
+	for policy := range allocators {
+		policies = append(policies, policy)
+	}
+	sort.Slice(policies, func(p, q int) bool {
+		return policies[p] < policies[q]
+	})
+	return policies
+}
+
+// getAllocator finds the allocator for the chosen policy, defaulting to EarliestDeadlineFirst
+func getAllocator(opts Options) (allocator, error) {
+	policy := opts.Policy
+	if policy == "" {
+		policy = EarliestDeadlineFirst
+	}
+	allocate, ok := allocators[policy]
+	if !ok {
+		names := make([]string, 0, len(allocators))
+		for _, known := range Policies() {
+			names = append(names, string(known))
+		}
+		return nil, fmt.Errorf("unknown policy %q, choose one of %s", policy, strings.Join(names, ", "))
+	}
+	return allocate, nil
+}
+
+// bestEffort cuts the work on the plan's deadlines down to what fits, as allocated, leaving
+// out those given nothing, and returns how much work is left undone on each deadline
+func (p *plan) bestEffort(allocate allocator) (shortfalls []Shortfall) {
+	allocation := allocateAfterPrerequisites(p.deadlines, allocate)
+	var kept []deadline
+	for i, deadline := range p.deadlines {
+		if allocation[i] < deadline.slotsRemaining {
+			minutes := deadline.MinutesRemaining - float64(allocation[i])*p.g.Work.Minutes()
+			shortfalls = append(shortfalls, Shortfall{Deadline: deadline.Deadline, Minutes: minutes})
+		}
+		if allocation[i] > 0 {
+			deadline.slotsRemaining = allocation[i]
+			kept = append(kept, deadline)
+		}
+	}
+	p.deadlines = kept
+	return shortfalls
+}
+
+// allocateAfterPrerequisites allocates slots to the deadlines, giving none to those depending
+// on a deadline whose work is not all allocated, as they could never be started, and sharing
+// out what they would have had between the rest
+func allocateAfterPrerequisites(deadlines []deadline, allocate allocator) []int {
+	cut := make([]bool, len(deadlines))
+	for {
+		deadlinesCopy := copyDeadlines(deadlines)
+		for i := range deadlinesCopy {
+			if cut[i] {
+				deadlinesCopy[i].slotsRemaining = 0
+			}
+		}
+		allocation := allocate(deadlinesCopy)
+		changed := false
+		for i := range deadlines {
+			if !cut[i] && prerequisiteShort(deadlines, allocation, i) {
+				cut[i] = true
+				changed = true
+			}
+		}
+		if !changed {
+			return allocation
+		}
+	}
+}
+
+// prerequisiteShort says whether any of the deadlines the deadline at index depends on is
+// allocated less than all of its work
+func prerequisiteShort(deadlines []deadline, allocation []int, index int) bool {
+	for _, prerequisite := range deadlines[index].prerequisites {
+		for j, other := range deadlines {
+			if other.Deadline == prerequisite && allocation[j] < other.slotsRemaining {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allocated copies the deadlines with the slots allocated to them as the work remaining
+func allocated(deadlines []deadline, allocation []int) []deadline {
+	deadlinesCopy := copyDeadlines(deadlines)
+	for i := range deadlinesCopy {
+		deadlinesCopy[i].slotsRemaining = allocation[i]
+	}
+	return deadlinesCopy
+}
+
+// allocateInOrder gives each deadline, in the order given, as many of the slots it needs as
+// it can have while every allocation can still be met
+func allocateInOrder(deadlines []deadline, allocation []int, order []int) {
+	for _, i := range order {
+		// the most extra slots that fit, found by halving the range it could be in
+		wanted := deadlines[i].slotsRemaining
+		if deadlines[i].slotsUsable < wanted {
+			wanted = deadlines[i].slotsUsable
+		}
+		low, high := 0, wanted-allocation[i]
+		for low < high {
+			middle := (low + high + 1) / 2
+			allocation[i] += middle
+			if _, _, possible := possibleTimetabling(allocated(deadlines, allocation)); possible {
+				low = middle
+			} else {
+				high = middle - 1
+			}
+			allocation[i] -= middle
+		}
+		allocation[i] += low
+	}
+}
+
+func allocateEarliestFirst(deadlines []deadline) []int {
+	allocation := make([]int, len(deadlines))
+	order := make([]int, len(deadlines))
+	for i := range order {
+		order[i] = i
+	}
+	allocateInOrder(deadlines, allocation, order)
+	return allocation
+}
+
+func allocateByPriority(deadlines []deadline) []int {
+	allocation := make([]int, len(deadlines))
+	order := make([]int, len(deadlines))
+	for i := range order {
+		order[i] = i
+	}
+	// deadlines are sorted, so ties go to the earliest
+	sort.SliceStable(order, func(p, q int) bool {
+		return deadlines[order[p]].Priority > deadlines[order[q]].Priority
+	})
+	allocateInOrder(deadlines, allocation, order)
+	return allocation
+}
+
+// allocateProportionally finds the largest share of their work every deadline can have,
+// then hands out what is left over earliest deadline first
+func allocateProportionally(deadlines []deadline) []int {
+	share := func(fraction float64) []int {
+		allocation := make([]int, len(deadlines))
+		for i, deadline := range deadlines {
+			allocation[i] = int(math.Floor(fraction * float64(deadline.slotsRemaining)))
+			if allocation[i] > deadline.slotsUsable {
+				allocation[i] = deadline.slotsUsable
+			}
+		}
+		return allocation
+	}
+	fits := func(allocation []int) bool {
+		_, _, possible := possibleTimetabling(allocated(deadlines, allocation))
+		return possible
+	}
+	low, high := 0.0, 1.0
+	for i := 0; i < 50; i++ {
+		middle := (low + high) / 2
+		if fits(share(middle)) {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	allocation := share(low)
+	order := make([]int, len(deadlines))
+	for i := range order {
+		order[i] = i
+	}
+	allocateInOrder(deadlines, allocation, order)
+	return allocation
+}
@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocate(t *testing.T) {
+	// 4 slots free before the first deadline, and 6 before the second
+	deadlines := []deadline{
+		{Deadline: &Deadline{Deadline: types.Deadline{Name: "first"}}, slotsRemaining: 4, slotsAvailable: 4, slotsUsable: 4},
+		{Deadline: &Deadline{Deadline: types.Deadline{Name: "second", Priority: 1}}, slotsRemaining: 4, slotsAvailable: 6, slotsUsable: 6},
+	}
+	assert.Equal(t, []int{4, 2}, allocateEarliestFirst(deadlines))
+	assert.Equal(t, []int{2, 4}, allocateByPriority(deadlines))
+	assert.Equal(t, []int{3, 3}, allocateProportionally(deadlines))
+
+	// nothing is taken from deadlines that fit
+	deadlines[1].slotsAvailable, deadlines[1].slotsUsable = 10, 10
+	for _, allocate := range allocators {
+		assert.Equal(t, []int{4, 4}, allocate(deadlines))
+	}
+
+	_, err := getAllocator(Options{Policy: "random"})
+	assert.Error(t, err)
+}
+
+func TestGenerateBestEffort(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 100, DeadlineTime: now.Add(time.Hour)}},
+			{Deadline: types.Deadline{Name: "slides", MinutesRemaining: 40, DeadlineTime: now.Add(90 * time.Minute)}},
+		},
+	}
+	_, err := Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+
+	timetable, err := Generate(context.Background(), in, Options{Now: now, BestEffort: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []Shortfall{{Deadline: &in.Deadlines[0], Minutes: 50}, {Deadline: &in.Deadlines[1], Minutes: 15}}, timetable.Shortfalls)
+	var names []string
+	for _, slot := range timetable.Slots {
+		names = append(names, slot.Deadline.Name)
+	}
+	assert.Equal(t, []string{"essay", "essay", "slides"}, names)
+
+	// the slides are done in full if they are more important
+	in.Deadlines[1].Priority = 1
+	timetable, err = Generate(context.Background(), in, Options{Now: now, BestEffort: true, Policy: ByPriority})
+	assert.NoError(t, err)
+	assert.Equal(t, []Shortfall{{Deadline: &in.Deadlines[0], Minutes: 75}}, timetable.Shortfalls)
+}
+
+func TestGenerateBestEffortDependencies(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "final", MinutesRemaining: 100, DeadlineTime: now.Add(4 * time.Hour), Priority: 1, DependsOn: []string{"draft"}}},
+			{Deadline: types.Deadline{Name: "draft", MinutesRemaining: 300, DeadlineTime: now.Add(4 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "notes", MinutesRemaining: 50, DeadlineTime: now.Add(4 * time.Hour)}},
+		},
+	}
+	// the final cannot be started while the draft is short, however important it is, so its
+	// time goes to the rest
+	for _, policy := range Policies() {
+		timetable, err := Generate(context.Background(), in, Options{Now: now, BestEffort: true, Policy: policy})
+		assert.NoError(t, err)
+		count := map[string]int{}
+		for _, slot := range timetable.Slots {
+			if slot.Kind == DeadlineSlot {
+				count[slot.Deadline.Name]++
+			}
+		}
+		assert.Zero(t, count["final"], policy)
+		assert.Equal(t, 8, count["draft"]+count["notes"], policy)
+		assert.Contains(t, timetable.Shortfalls, Shortfall{Deadline: &in.Deadlines[0], Minutes: 100}, policy)
+	}
+}
@@ -0,0 +1,138 @@
+package backend
+
+// run is the work on a deadline in the slots just before the one being filled
+type run struct {
+	deadline *Deadline
+	length   int
+}
+
+// next is the run once the slot being filled is given to chosen, or to nothing if nil
+func (r run) next(chosen *Deadline) run {
+	if chosen == nil {
+		return run{}
+	}
+	if chosen == r.deadline {
+		return run{deadline: chosen, length: r.length + 1}
+	}
+	return run{deadline: chosen, length: 1}
+}
+
+// shortestBlock is the fewest slots in a row a block of work on the deadline can take up,
+// which for the last of its work is whatever is left
+func (d deadline) shortestBlock() int {
+	shortest := d.MinBlock
+	if shortest > d.slotsRemaining {
+		shortest = d.slotsRemaining
+	}
+	if shortest < 1 {
+		return 1
+	}
+	return shortest
+}
+
+// freeRun counts the free slots in a row from the one at index
+func freeRun(timetable []timetableElement, index int) int {
+	count := 0
+	for _, slot := range timetable[index:] {
+		if !slot.free() {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// allowedCandidates narrows the candidates for the slot at index to those whose block lengths
+// and daily limits allow it, given the work before it: a deadline given as much as it can be
+// that day has to stop, a block shorter than its deadline's minBlock has to carry on, one as
+// long as its maxBlock has to stop, and a new block needs room for the shortest it can be
+// if none of the candidates are allowed, the slot is left free if there is time to, and
+// otherwise the error is an *InfeasibleError for the deadline that would go unmet
+func allowedCandidates(timetable []timetableElement, index int, deadlines []deadline, candidates []int, w worked) ([]int, error) {
+	var allowed []int
+	ahead := -1
+	for _, candidate := range candidates {
+		deadline := deadlines[candidate]
+		if w.cappedToday(deadline) {
+			continue
+		}
+		if deadline.Deadline == w.deadline {
+			if w.length < deadline.MinBlock {
+				return []int{candidate}, nil
+			}
+			if deadline.MaxBlock == 0 || w.length < deadline.MaxBlock {
+				allowed = append(allowed, candidate)
+			}
+			continue
+		}
+		if ahead < 0 {
+			ahead = freeRun(timetable, index)
+		}
+		if shortest := deadline.shortestBlock(); ahead >= shortest && deadline.slotsAvailable >= shortest {
+			allowed = append(allowed, candidate)
+		}
+	}
+	if len(allowed) == 0 {
+		skipped := copyDeadlines(deadlines)
+		passSlot(skipped)
+		if noFit, short, possible := possibleTimetabling(skipped); !possible {
+			return nil, &InfeasibleError{Deadline: *noFit.Deadline, SlotsShort: short}
+		}
+		return nil, nil
+	}
+	return allowed, nil
+}
+
+// penaliseSwitching scales down the weights of the candidates other than the deadline the
+// run is on, if it is one of them, so work tends to carry on rather than switch
+func penaliseSwitching(weights []float64, deadlines []deadline, candidates []int, r run, penalty float64) {
+	carriesOn := false
+	for _, candidate := range candidates {
+		if deadlines[candidate].Deadline == r.deadline {
+			carriesOn = true
+		}
+	}
+	if !carriesOn || penalty == 0 {
+		return
+	}
+	for _, candidate := range candidates {
+		if deadlines[candidate].Deadline != r.deadline {
+			weights[candidate] *= 1 - penalty
+		}
+	}
+}
+
+// usableSlots is the most of the free slots in timetablePart that a deadline could take up
+// on its own, in blocks no shorter than shortest and, unless longest is 0, no longer than
+// longest, with a slot between blocks
+func usableSlots(timetablePart []timetableElement, shortest int, longest int) int {
+	usable := 0
+	for i := 0; i < len(timetablePart); {
+		length := freeRun(timetablePart, i)
+		usable += usableInRun(length, shortest, longest)
+		i += length + 1
+	}
+	return usable
+}
+
+// usableInRun is the most slots of a run of free slots that blocks can take up
+func usableInRun(length int, shortest int, longest int) int {
+	if longest == 0 || longest > length {
+		longest = length
+	}
+	// best[i] is the most the first i slots of the run can give
+	best := make([]int, length+1)
+	for i := 1; i <= length; i++ {
+		best[i] = best[i-1]
+		for block := shortest; block <= longest && block <= i; block++ {
+			before := 0
+			if i-block > 0 {
+				before = best[i-block-1]
+			}
+			if block+before > best[i] {
+				best[i] = block + before
+			}
+		}
+	}
+	return best[length]
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsableInRun(t *testing.T) {
+	assert.Equal(t, 5, usableInRun(5, 2, 0))
+	assert.Equal(t, 4, usableInRun(5, 1, 2))
+	assert.Equal(t, 3, usableInRun(5, 3, 3))
+	assert.Equal(t, 4, usableInRun(7, 2, 2))
+	assert.Equal(t, 0, usableInRun(1, 2, 0))
+}
+
+// runs lists the names of the deadlines worked on in the timetable, with how many slots in a
+// row each was worked on for
+func runs(timetable *Timetable) (names []string, lengths []int) {
+	for i, slot := range timetable.Slots {
+		if slot.Kind != DeadlineSlot {
+			continue
+		}
+		if i > 0 && timetable.Slots[i-1].Deadline == slot.Deadline {
+			lengths[len(lengths)-1]++
+			continue
+		}
+		names = append(names, slot.Deadline.Name)
+		lengths = append(lengths, 1)
+	}
+	return names, lengths
+}
+
+func TestGenerateBlocks(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "deep", MinutesRemaining: 200, DeadlineTime: now.Add(8 * time.Hour), MinBlock: 4}},
+			{Deadline: types.Deadline{Name: "shallow", MinutesRemaining: 100, DeadlineTime: now.Add(8 * time.Hour), MaxBlock: 1}},
+		},
+	}
+	for _, strategy := range Strategies() {
+		for seed := int64(1); seed <= 5; seed++ {
+			timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: strategy, Seed: &seed})
+			assert.NoError(t, err)
+			names, lengths := runs(timetable)
+			var deep []int
+			for i, name := range names {
+				if name == "deep" {
+					deep = append(deep, lengths[i])
+				} else {
+					assert.Equal(t, 1, lengths[i])
+				}
+			}
+			// the last block can be whatever is left
+			for _, length := range deep[:len(deep)-1] {
+				assert.GreaterOrEqual(t, length, 4)
+			}
+		}
+	}
+
+	// with switching ruled out, each deadline is done in one go
+	in.Deadlines[0].MinBlock, in.Deadlines[1].MaxBlock = 0, 0
+	in.Settings.SwitchPenalty = 1
+	timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: Spread})
+	assert.NoError(t, err)
+	names, _ := runs(timetable)
+	assert.Len(t, names, 2)
+
+	in.Settings.SwitchPenalty = 2
+	_, err = Generate(context.Background(), in, Options{Now: now})
+	assert.ErrorIs(t, err, ErrInvalidSwitchPenalty)
+}
+
+func TestGenerateBlocksInfeasible(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	// the lecture leaves runs of 2 and 1 free slots, too short for the essay's blocks
+	in := &Input{
+		Events: []Event{
+			{Event: types.Event{Name: "lecture", StartTime: now.Add(time.Hour), EndTime: now.Add(90 * time.Minute)}},
+		},
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 75, DeadlineTime: now.Add(2 * time.Hour), MinBlock: 3}},
+		},
+	}
+	_, err := Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, 3, infeasibleErr.SlotsShort)
+	assert.Equal(t, 3, infeasibleErr.Report.Deadlines[0].ShortSlots)
+
+	// runs of 6 and 2 free slots fit either of these blocks, but not both
+	in.Events[0].StartTime, in.Events[0].EndTime = now.Add(3*time.Hour), now.Add(210*time.Minute)
+	in.Deadlines = []Deadline{
+		{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 125, DeadlineTime: now.Add(270 * time.Minute), MinBlock: 5}},
+		{Deadline: types.Deadline{Name: "report", MinutesRemaining: 75, DeadlineTime: now.Add(270 * time.Minute), MinBlock: 3}},
+	}
+	for _, strategy := range Strategies() {
+		_, err = Generate(context.Background(), in, Options{Now: now, Strategy: strategy})
+		assert.ErrorAs(t, err, &infeasibleErr, strategy)
+	}
+
+	problems := checkDeadlines([]Deadline{{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 25, MinBlock: 3, MaxBlock: 2}}})
+	assert.Len(t, problems, 1)
+	assert.ErrorIs(t, problems[0], ErrInvalidBlocks)
+}
+
+func TestGenerateBlocksAfterDependencies(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	// the draft takes the first three slots, leaving four for the final's blocks of one
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "draft", MinutesRemaining: 75, DeadlineTime: now.Add(3 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "final", MinutesRemaining: 75, DeadlineTime: now.Add(210 * time.Minute), MaxBlock: 1, DependsOn: []string{"draft"}}},
+		},
+	}
+	_, err := Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, "final", infeasibleErr.Deadline.Name)
+	assert.Equal(t, 1, infeasibleErr.SlotsShort)
+
+	// with five, they fit
+	in.Deadlines[1].DeadlineTime = now.Add(4 * time.Hour)
+	for _, strategy := range Strategies() {
+		timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: strategy})
+		assert.NoError(t, err)
+		names, lengths := runs(timetable)
+		assert.Equal(t, []string{"draft", "final", "final", "final"}, names)
+		assert.Equal(t, []int{3, 1, 1, 1}, lengths)
+	}
+}
@@ -0,0 +1,287 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CapacityReport compares the work due by each deadline with the free slots there are for it
+type CapacityReport struct {
+	Start       time.Time          `json:"start"`
+	Deadlines   []DeadlineCapacity `json:"deadlines"`
+	Suggestions []Suggestion       `json:"suggestions,omitempty"`
+}
+
+// DeadlineCapacity is the work due by a deadline and the slots free for it, both since the
+// deadline before it and in total from the start of the timetable
+type DeadlineCapacity struct {
+	Deadline       Deadline `json:"deadline"`
+	Slots          int      `json:"slots"`
+	FreeSlots      int      `json:"freeSlots"`
+	TotalSlots     int      `json:"totalSlots"`
+	TotalFreeSlots int      `json:"totalFreeSlots"`
+	// WindowFreeSlots, for a deadline with a start time, is the free slots between then and
+	// when it is due
+	WindowFreeSlots int `json:"windowFreeSlots,omitempty"`
+	// ShortSlots is how many more slots would be needed to meet every deadline up to this one,
+	// each within its window
+	ShortSlots int `json:"shortSlots"`
+	// Events are those taking up slots since the deadline before, which would otherwise be free
+	Events []EventCapacity `json:"events,omitempty"`
+	// Habits are those taking up slots since the deadline before
+	Habits []EventCapacity `json:"habits,omitempty"`
+}
+
+// EventCapacity is the number of slots an event or habit takes up, over all of its occurrences
+type EventCapacity struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Slots  int    `json:"slots"`
+}
+
+// actions a Suggestion can make
+const (
+	DropEvent    = "drop event"
+	PushDeadline = "push deadline"
+	TrimDeadline = "trim deadline"
+)
+
+// Suggestion is a change to the input that would make room for a deadline that cannot be met
+type Suggestion struct {
+	// Action is one of DropEvent, PushDeadline and TrimDeadline
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	// By is how much later to push a deadline, or how much work to trim from it
+	By time.Duration `json:"by,omitempty"`
+	// For is the deadline the change makes room for
+	For string `json:"for"`
+}
+
+func (s Suggestion) String() string {
+	switch s.Action {
+	case PushDeadline:
+		return fmt.Sprintf("%s %s by %s", s.Action, s.Name, formatDuration(s.By))
+	case TrimDeadline:
+		return fmt.Sprintf("%s %s by %d minutes", s.Action, s.Name, int(s.By.Minutes()))
+	default:
+		return fmt.Sprintf("%s %s, to make room for %s", s.Action, s.Name, s.For)
+	}
+}
+
+// Feasible says whether every deadline can be met
+func (r *CapacityReport) Feasible() bool {
+	for _, capacity := range r.Deadlines {
+		if capacity.ShortSlots > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String prints the report with a line for each deadline
+func (r *CapacityReport) String() string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("Capacity from %s\n", r.Start.Format("Jan 2 15:04")))
+	for _, capacity := range r.Deadlines {
+		builder.WriteString(fmt.Sprintf("%s %s: %d slot(s) of work and %d free since the last deadline, %d and %d in total\n",
+			capacity.Deadline.DeadlineTime.Format("Jan 2 15:04"), capacity.Deadline.Name, capacity.Slots, capacity.FreeSlots, capacity.TotalSlots, capacity.TotalFreeSlots))
+		if capacity.Deadline.StartTime != nil {
+			builder.WriteString(fmt.Sprintf("  not before %s, leaving %d free slot(s)\n", capacity.Deadline.StartTime.Format("Jan 2 15:04"), capacity.WindowFreeSlots))
+		}
+		if len(capacity.Events) > 0 {
+			events := make([]string, len(capacity.Events))
+			for i, event := range capacity.Events {
+				events[i] = fmt.Sprintf("%s (%d)", event.Name, event.Slots)
+			}
+			builder.WriteString(fmt.Sprintf("  slots taken by events: %s\n", strings.Join(events, ", ")))
+		}
+		if len(capacity.Habits) > 0 {
+			habits := make([]string, len(capacity.Habits))
+			for i, habit := range capacity.Habits {
+				habits[i] = fmt.Sprintf("%s (%d)", habit.Name, habit.Slots)
+			}
+			builder.WriteString(fmt.Sprintf("  slots taken by habits: %s\n", strings.Join(habits, ", ")))
+		}
+		if capacity.ShortSlots > 0 {
+			builder.WriteString(fmt.Sprintf("  %d slot(s) short\n", capacity.ShortSlots))
+		}
+	}
+	if len(r.Suggestions) > 0 {
+		builder.WriteString("Suggestions:\n")
+		for _, suggestion := range r.Suggestions {
+			builder.WriteString(fmt.Sprintf("  %s\n", suggestion))
+		}
+	}
+	return builder.String()
+}
+
+// maxSuggestedEvents is the most events suggested to be dropped for one deadline
+const maxSuggestedEvents = 3
+
+// pushHorizon is how far past a deadline to look for free slots to push it into
+const pushHorizon = 366 * 24 * time.Hour
+
+// Check reports on the capacity for each of the input's deadlines without generating a
+// timetable; if the deadlines cannot all be met, the error is an *InfeasibleError holding
+// the same report
+func Check(in *Input, opts Options) (*CapacityReport, error) {
+	opts.SwitchPenalty = opts.switchPenalty(in.Settings)
+	if opts.SwitchPenalty < 0 || opts.SwitchPenalty > 1 {
+		return nil, validationError([]error{fmt.Errorf("%w: %v", ErrInvalidSwitchPenalty, opts.SwitchPenalty)})
+	}
+	p, err := newPlan(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	// as Generate does, keeping to a previous timetable is dropped if it leaves too little time
+	if p.frozen > 0 && p.feasibilityError() != nil {
+		opts.Previous = nil
+		if p, err = newPlan(in, opts); err != nil {
+			return nil, err
+		}
+	}
+	return p.capacityReport(), p.feasibilityError()
+}
+
+// capacityReport reports on the capacity for each deadline, from the bookkeeping filled in
+// by fillDeadlines
+func (p *plan) capacityReport() *CapacityReport {
+	report := &CapacityReport{Start: p.g.start}
+	startIndex, totalSlots, previousFree, worstShort := 0, 0, 0, 0
+	narrowed, order := windows(p.deadlines)
+	spares := make([]int, len(p.deadlines))
+	for i, spare := range spareSlots(narrowed, math.MaxInt32) {
+		spares[order[i]] = spare
+	}
+	for i, deadline := range p.deadlines {
+		endIndex := clamp(p.g.floor(deadline.DeadlineTime), len(p.timetable))
+		totalSlots += deadline.slotsRemaining
+		capacity := DeadlineCapacity{
+			Deadline:       *deadline.Deadline,
+			Slots:          deadline.slotsRemaining,
+			FreeSlots:      deadline.slotsAvailable - previousFree,
+			TotalSlots:     totalSlots,
+			TotalFreeSlots: deadline.slotsAvailable,
+			Events:         eventsTakingSlots(p.timetable[startIndex:endIndex]),
+			Habits:         habitsTakingSlots(p.timetable[startIndex:endIndex]),
+		}
+		if deadline.StartTime != nil {
+			capacity.WindowFreeSlots = deadline.slotsAvailable - deadline.slotsBeforeStart
+		}
+		if spares[i] < 0 {
+			capacity.ShortSlots = -spares[i]
+		}
+		if short := deadline.slotsRemaining - deadline.slotsUsable; short > capacity.ShortSlots {
+			capacity.ShortSlots = short
+		}
+		report.Deadlines = append(report.Deadlines, capacity)
+		// deadlines after one that cannot be met are short too, so only suggest how to make
+		// room when a deadline is shorter than those before it
+		if capacity.ShortSlots > worstShort {
+			report.Suggestions = append(report.Suggestions, p.suggestions(i, capacity.ShortSlots, endIndex)...)
+			worstShort = capacity.ShortSlots
+		}
+		startIndex, previousFree = endIndex, deadline.slotsAvailable
+	}
+	return report
+}
+
+// eventsTakingSlots counts the slots each event takes up that would otherwise be free
+func eventsTakingSlots(timetablePart []timetableElement) (events []EventCapacity) {
+	indices := map[[2]string]int{}
+	for _, slot := range timetablePart {
+		if slot.event == nil || slot.off {
+			continue
+		}
+		key := [2]string{slot.event.Name, slot.event.Source}
+		index, ok := indices[key]
+		if !ok {
+			index = len(events)
+			indices[key] = index
+			events = append(events, EventCapacity{Name: slot.event.Name, Source: slot.event.Source})
+		}
+		events[index].Slots++
+	}
+	return events
+}
+
+// habitsTakingSlots counts the slots each habit takes up that would otherwise be free
+func habitsTakingSlots(timetablePart []timetableElement) (habits []EventCapacity) {
+	indices := map[*Periodic]int{}
+	for _, slot := range timetablePart {
+		if slot.habit == nil || slot.event != nil || slot.off {
+			continue
+		}
+		index, ok := indices[slot.habit]
+		if !ok {
+			index = len(habits)
+			indices[slot.habit] = index
+			habits = append(habits, EventCapacity{Name: slot.habit.Name, Source: slot.habit.Source})
+		}
+		habits[index].Slots++
+	}
+	return habits
+}
+
+// suggestions lists changes that would each make room for the short slots needed by the
+// deadline at index, whose slot is endIndex
+func (p *plan) suggestions(index int, short int, endIndex int) (suggestions []Suggestion) {
+	name := p.deadlines[index].Name
+
+	// dropping an event taking enough slots, preferring the smallest that is enough
+	events := eventsTakingSlots(p.timetable[:endIndex])
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].Slots < events[b].Slots
+	})
+	dropped := 0
+	for _, event := range events {
+		if event.Slots >= short && dropped < maxSuggestedEvents {
+			suggestions = append(suggestions, Suggestion{Action: DropEvent, Name: event.Name, For: name})
+			dropped++
+		}
+	}
+
+	// pushing the deadline back past enough free slots
+	found := 0
+	for i := endIndex; i < endIndex+int(pushHorizon/p.g.Slot); i++ {
+		free := p.g.availability.available(p.g.slotStart(i), p.g.slotStart(i+1))
+		if i < len(p.timetable) {
+			free = p.timetable[i].free()
+		}
+		if free {
+			found++
+		}
+		if found == short {
+			suggestions = append(suggestions, Suggestion{Action: PushDeadline, Name: name, By: p.g.slotStart(i + 1).Sub(p.deadlines[index].DeadlineTime), For: name})
+			break
+		}
+	}
+
+	// trimming the work on the lowest priority deadline with enough to trim, nearest first
+	trim := -1
+	for i := index; i >= 0; i-- {
+		if p.deadlines[i].slotsRemaining >= short && (trim < 0 || p.deadlines[i].Priority < p.deadlines[trim].Priority) {
+			trim = i
+		}
+	}
+	if trim >= 0 {
+		deadline := p.deadlines[trim]
+		trimmed := time.Duration(deadline.MinutesRemaining*float64(time.Minute)) - time.Duration(deadline.slotsRemaining-short)*p.g.Work
+		// round up to whole minutes, so the trim is always enough
+		trimmed = (trimmed + time.Minute - 1).Truncate(time.Minute)
+		suggestions = append(suggestions, Suggestion{Action: TrimDeadline, Name: deadline.Name, By: trimmed, For: name})
+	}
+	return suggestions
+}
+
+// formatDuration prints a duration in hours and minutes
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%d minutes", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,59 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Events: []Event{
+			{Event: types.Event{Name: "lecture", StartTime: now, EndTime: now.Add(time.Hour)}},
+			{Event: types.Event{Name: "lunch", StartTime: now.Add(3 * time.Hour), EndTime: now.Add(4 * time.Hour)}},
+		},
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 100, DeadlineTime: now.Add(3 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "slides", MinutesRemaining: 60, DeadlineTime: now.Add(4 * time.Hour)}},
+		},
+	}
+	report, err := Check(in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, report, infeasibleErr.Report)
+	assert.False(t, report.Feasible())
+
+	// the lecture takes 2 of the 6 slots before the essay is due, leaving 4 for its 4
+	essay := report.Deadlines[0]
+	assert.Equal(t, DeadlineCapacity{Deadline: in.Deadlines[0], Slots: 4, FreeSlots: 4, TotalSlots: 4, TotalFreeSlots: 4, Events: []EventCapacity{{Name: "lecture", Slots: 2}}}, essay)
+	// and lunch takes all the slots before the slides are due
+	slides := report.Deadlines[1]
+	assert.Equal(t, DeadlineCapacity{Deadline: in.Deadlines[1], Slots: 3, FreeSlots: 0, TotalSlots: 7, TotalFreeSlots: 4, ShortSlots: 3, Events: []EventCapacity{{Name: "lunch", Slots: 2}}}, slides)
+
+	assert.Equal(t, []Suggestion{
+		{Action: PushDeadline, Name: "slides", By: 90 * time.Minute, For: "slides"},
+		{Action: TrimDeadline, Name: "slides", By: 60 * time.Minute, For: "slides"},
+	}, report.Suggestions)
+	assert.Contains(t, err.Error(), "Jan 7 13:00 slides: 3 slot(s) of work and 0 free since the last deadline, 7 and 4 in total\n  slots taken by events: lunch (2)\n  3 slot(s) short\n")
+	assert.Contains(t, err.Error(), "Suggestions:\n  push deadline slides by 1h30m\n  trim deadline slides by 60 minutes\n")
+
+	// with less work, both events could be dropped to make room
+	in.Deadlines[1].MinutesRemaining = 25
+	report, err = Check(in, Options{Now: now})
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, []Suggestion{
+		{Action: DropEvent, Name: "lecture", For: "slides"},
+		{Action: DropEvent, Name: "lunch", For: "slides"},
+		{Action: PushDeadline, Name: "slides", By: 30 * time.Minute, For: "slides"},
+		{Action: TrimDeadline, Name: "slides", By: 25 * time.Minute, For: "slides"},
+	}, report.Suggestions)
+
+	in.Deadlines = in.Deadlines[:1]
+	report, err = Check(in, Options{Now: now})
+	assert.NoError(t, err)
+	assert.True(t, report.Feasible())
+	assert.Empty(t, report.Suggestions)
+}
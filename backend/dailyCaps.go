@@ -0,0 +1,169 @@
+package backend
+
+import "fmt"
+
+// worked is the work done before the slot being filled that limits what can go in it: the run
+// of work on one deadline just before it, and the slots given to each deadline that day, along
+// with how many were given to any and the most that can be
+type worked struct {
+	run
+	today    map[*Deadline]int
+	total    int
+	totalCap int
+}
+
+// at moves on to the slot at index, which starts a new day of work if it is the first of a day
+func (w worked) at(timetable []timetableElement, index int) worked {
+	if timetable[index].dayStart || w.today == nil {
+		w.today = map[*Deadline]int{}
+		w.total = 0
+		w.totalCap = timetable[index].dayCap
+	}
+	if !timetable[index].free() {
+		w.run = run{}
+	}
+	// work kept from a previous timetable counts towards the day's
+	if timetable[index].frozen {
+		w.today[timetable[index].deadline]++
+		w.total++
+	}
+	return w
+}
+
+// next is the work done once the slot being filled is given to chosen, or to nothing if nil
+func (w worked) next(chosen *Deadline) worked {
+	w.run = w.run.next(chosen)
+	if chosen != nil {
+		w.today[chosen]++
+		w.total++
+	}
+	return w
+}
+
+// cappedToday says whether the deadline has been given as many slots that day as it can be,
+// or the deadlines between them have
+func (w worked) cappedToday(d deadline) bool {
+	return (d.slotsPerDay > 0 && w.today[d.Deadline] >= d.slotsPerDay) || (w.totalCap > 0 && w.total >= w.totalCap)
+}
+
+// slotsPerDay is how many slots of work maxMinutesPerDay allows, or 0 for no limit
+func (d Durations) slotsPerDay(maxMinutesPerDay int) int {
+	return int(float64(maxMinutesPerDay) / d.Work.Minutes())
+}
+
+// checkMaxMinutesPerDay makes sure maxMinutesPerDay, if set, allows at least a slot of work
+func (d Durations) checkMaxMinutesPerDay(maxMinutesPerDay int, name string) (problems []error) {
+	if maxMinutesPerDay < 0 || (maxMinutesPerDay > 0 && d.slotsPerDay(maxMinutesPerDay) == 0) {
+		problems = append(problems, fmt.Errorf("%w: %s allows %d", ErrInvalidMaxMinutesPerDay, name, maxMinutesPerDay))
+	}
+	return problems
+}
+
+// checkDeadlineCaps checks the maxMinutesPerDay of each deadline
+func checkDeadlineCaps(d Durations, deadlines []Deadline) (problems []error) {
+	for _, deadline := range deadlines {
+		problems = append(problems, d.checkMaxMinutesPerDay(deadline.MaxMinutesPerDay, deadline.Name)...)
+	}
+	return problems
+}
+
+// fillDays marks the slots that start a day
+func fillDays(g grid, timetable []timetableElement) {
+	for i := range timetable {
+		year, month, day := g.slotStart(i).Date()
+		previousYear, previousMonth, previousDay := g.slotStart(i - 1).Date()
+		timetable[i].dayStart = i == 0 || year != previousYear || month != previousMonth || day != previousDay
+	}
+}
+
+// fillWithDailyCap sets the most slots of work on deadlines in each day, on its first slot
+func fillWithDailyCap(g grid, timetable []timetableElement) {
+	for i, slot := range timetable {
+		if slot.dayStart {
+			timetable[i].dayCap = g.maxSlotsPerDay
+		}
+	}
+}
+
+// usableSlotsPerDay is usableSlots, with at most perDay of the slots in each day
+func usableSlotsPerDay(timetablePart []timetableElement, shortest int, longest int, perDay int) int {
+	usable, dayStart := 0, 0
+	for i := 1; i <= len(timetablePart); i++ {
+		if i < len(timetablePart) && !timetablePart[i].dayStart {
+			continue
+		}
+		inDay := usableSlots(timetablePart[dayStart:i], shortest, longest)
+		if inDay > perDay {
+			inDay = perDay
+		}
+		usable += inDay
+		dayStart = i
+	}
+	return usable
+}
+
+// dailyCapError finds a stretch of time, from when a deadline can be started until when one is
+// due, with more work to do in it than maxSlotsPerDay a day leaves room for, and returns an
+// *InfeasibleError for the deadline due at its end if there is one
+func (p *plan) dailyCapError() error {
+	if p.g.maxSlotsPerDay == 0 {
+		return nil
+	}
+	starts := make([]int, len(p.deadlines))
+	ends := make([]int, len(p.deadlines))
+	for i, deadline := range p.deadlines {
+		ends[i] = clamp(p.g.floor(deadline.DeadlineTime), len(p.timetable))
+		if deadline.StartTime != nil {
+			starts[i] = clamp(p.g.ceil(*deadline.StartTime), ends[i])
+		}
+	}
+	for _, from := range starts {
+		for i, to := range ends {
+			if to <= from {
+				continue
+			}
+			due := 0
+			for j := range p.deadlines {
+				if starts[j] >= from && ends[j] <= to {
+					due += p.deadlines[j].slotsRemaining
+				}
+			}
+			if room := roomWithDailyCap(p.timetable, from, to, p.g.maxSlotsPerDay); due > room {
+				return &InfeasibleError{Deadline: *p.deadlines[i].Deadline, SlotsShort: due - room, Report: p.capacityReport()}
+			}
+		}
+	}
+	return nil
+}
+
+// roomWithDailyCap counts the free slots from the one at from up to the one at to, with no more
+// in a day than perDay less the slots kept from a previous timetable that day
+func roomWithDailyCap(timetable []timetableElement, from int, to int, perDay int) int {
+	room, day := 0, from
+	for day > 0 && !timetable[day].dayStart {
+		day--
+	}
+	for day < to {
+		end := day + 1
+		for end < len(timetable) && !timetable[end].dayStart {
+			end++
+		}
+		free, frozen := 0, 0
+		for i := day; i < end; i++ {
+			if timetable[i].frozen {
+				frozen++
+			}
+			if i >= from && i < to && timetable[i].free() {
+				free++
+			}
+		}
+		if left := perDay - frozen; free > left {
+			free = left
+		}
+		if free > 0 {
+			room += free
+		}
+		day = end
+	}
+	return room
+}
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// slotsPerDay counts the slots given to the named deadline on each day of the timetable
+func slotsPerDay(timetable *Timetable, name string) map[int]int {
+	counts := map[int]int{}
+	for _, slot := range timetable.Slots {
+		if slot.Kind == DeadlineSlot && slot.Deadline.Name == name {
+			counts[slot.Start.YearDay()]++
+		}
+	}
+	return counts
+}
+
+func TestGenerateDailyCap(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "project", MinutesRemaining: 400, DeadlineTime: now.Add(5 * 24 * time.Hour)}},
+		},
+		Settings: types.Settings{MaxMinutesPerDay: 100},
+	}
+	for _, strategy := range Strategies() {
+		timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: strategy})
+		assert.NoError(t, err)
+		total := 0
+		for _, count := range slotsPerDay(timetable, "project") {
+			assert.LessOrEqual(t, count, 4)
+			total += count
+		}
+		assert.Equal(t, 16, total)
+		// the rest of each day is left free rather than off
+		for _, slot := range timetable.Slots {
+			assert.NotEqual(t, OffSlot, slot.Kind)
+		}
+	}
+
+	// the limit is on the work on all the deadlines together
+	both := &Input{
+		Deadlines: []Deadline{
+			in.Deadlines[0],
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 200, DeadlineTime: now.Add(5 * 24 * time.Hour)}},
+		},
+		Settings: in.Settings,
+	}
+	for _, strategy := range Strategies() {
+		timetable, err := Generate(context.Background(), both, Options{Now: now, Strategy: strategy})
+		assert.NoError(t, err)
+		project, essay := slotsPerDay(timetable, "project"), slotsPerDay(timetable, "essay")
+		for day := range project {
+			assert.LessOrEqual(t, project[day]+essay[day], 4)
+		}
+	}
+
+	// due on the third morning, there are three days of work before it is due
+	in.Deadlines[0].DeadlineTime = now.Add(2 * 24 * time.Hour)
+	_, err := Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, 4, infeasibleErr.SlotsShort)
+
+	// two deadlines that each fit in a day's limit, but not together
+	both.Deadlines[0].MinutesRemaining, both.Deadlines[0].DeadlineTime = 100, now.Add(8*time.Hour)
+	both.Deadlines[1].MinutesRemaining, both.Deadlines[1].DeadlineTime = 100, now.Add(8*time.Hour)
+	_, err = Generate(context.Background(), both, Options{Now: now})
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, 4, infeasibleErr.SlotsShort)
+	both.Deadlines[1].DeadlineTime = now.Add(32 * time.Hour)
+	_, err = Generate(context.Background(), both, Options{Now: now})
+	assert.NoError(t, err)
+
+	_, err = Generate(context.Background(), in, Options{Now: now, MaxMinutesPerDay: 10})
+	assert.ErrorIs(t, err, ErrInvalidMaxMinutesPerDay)
+}
+
+func TestGenerateDeadlineDailyCap(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 100, DeadlineTime: now.Add(24 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "project", MinutesRemaining: 300, DeadlineTime: now.Add(7 * 24 * time.Hour), MaxMinutesPerDay: 50}},
+		},
+	}
+	for _, strategy := range Strategies() {
+		timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: strategy})
+		assert.NoError(t, err)
+		total := 0
+		for _, count := range slotsPerDay(timetable, "project") {
+			assert.LessOrEqual(t, count, 2)
+			total += count
+		}
+		assert.Equal(t, 12, total)
+	}
+
+	// four days of two slots each is not enough
+	in.Deadlines[1].DeadlineTime = now.Add(3 * 24 * time.Hour)
+	_, err := Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, "project", infeasibleErr.Deadline.Name)
+	assert.Equal(t, 4, infeasibleErr.SlotsShort)
+}
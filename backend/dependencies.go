@@ -0,0 +1,142 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// checkDependencies makes sure every deadline depended on exists, or is one of finished, and that
+// no deadlines depend on each other
+func checkDependencies(deadlines []Deadline, finished []string) (problems []error) {
+	graph := map[string][]string{}
+	var names []string
+	for _, deadline := range deadlines {
+		if _, ok := graph[deadline.Name]; !ok {
+			names = append(names, deadline.Name)
+		}
+		graph[deadline.Name] = append(graph[deadline.Name], deadline.DependsOn...)
+	}
+	// a deadline that is done depends on nothing left to do
+	for _, name := range finished {
+		if _, ok := graph[name]; !ok {
+			graph[name] = nil
+		}
+	}
+	for _, name := range names {
+		for _, dependency := range graph[name] {
+			if _, ok := graph[dependency]; !ok {
+				problems = append(problems, fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, name, dependency))
+			}
+		}
+	}
+
+	// depth first, where a deadline still on the path when it is reached again closes a cycle
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = onPath
+		path = append(path, name)
+		for _, dependency := range graph[name] {
+			switch state[dependency] {
+			case onPath:
+				start := 0
+				for path[start] != dependency {
+					start++
+				}
+				cycle := append(append([]string{}, path[start:]...), dependency)
+				problems = append(problems, fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> ")))
+			case unvisited:
+				if _, ok := graph[dependency]; ok {
+					visit(dependency)
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return problems
+}
+
+// prerequisites finds the deadlines each deadline depends on, by name
+func prerequisites(inputDeadlines []Deadline) [][]*Deadline {
+	byName := map[string][]*Deadline{}
+	for i := range inputDeadlines {
+		byName[inputDeadlines[i].Name] = append(byName[inputDeadlines[i].Name], &inputDeadlines[i])
+	}
+	found := make([][]*Deadline, len(inputDeadlines))
+	for i, deadline := range inputDeadlines {
+		for _, name := range deadline.DependsOn {
+			found[i] = append(found[i], byName[name]...)
+		}
+	}
+	return found
+}
+
+// blocked says whether any of the deadlines the deadline at index depends on still has work left
+func blocked(deadlines []deadline, index int) bool {
+	for _, prerequisite := range deadlines[index].prerequisites {
+		for _, other := range deadlines {
+			if other.Deadline == prerequisite && other.slotsRemaining > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// windows narrows each deadline's window to what is left once the work on the deadlines it
+// depends on is done, and before the work on those depending on it has to start, returning
+// the deadlines with their windows narrowed, sorted by when they must be done, along with
+// where each one was in deadlines
+func windows(deadlines []deadline) (narrowed []deadline, order []int) {
+	narrowed = copyDeadlines(deadlines)
+	order = make([]int, len(deadlines))
+	indices := map[*Deadline]int{}
+	for i, deadline := range deadlines {
+		order[i] = i
+		indices[deadline.Deadline] = i
+	}
+	// each pass narrows along one more dependency, so there are no more passes than deadlines
+	for pass := 0; pass < len(narrowed); pass++ {
+		changed := false
+		for i, deadline := range narrowed {
+			for _, prerequisite := range deadline.prerequisites {
+				j, ok := indices[prerequisite]
+				if !ok {
+					continue
+				}
+				if start := narrowed[j].slotsBeforeStart + narrowed[j].slotsRemaining; start > narrowed[i].slotsBeforeStart {
+					narrowed[i].slotsBeforeStart = start
+					changed = true
+				}
+				if end := narrowed[i].slotsAvailable - narrowed[i].slotsRemaining; end < narrowed[j].slotsAvailable {
+					narrowed[j].slotsAvailable = end
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	sort.SliceStable(order, func(p, q int) bool {
+		return narrowed[order[p]].slotsAvailable < narrowed[order[q]].slotsAvailable
+	})
+	sorted := make([]deadline, len(narrowed))
+	for i, index := range order {
+		sorted[i] = narrowed[index]
+	}
+	return sorted, order
+}
@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	deadlines := []Deadline{
+		{Deadline: types.Deadline{Name: "report", DependsOn: []string{"data"}}},
+		{Deadline: types.Deadline{Name: "slides", DependsOn: []string{"report"}}},
+		{Deadline: types.Deadline{Name: "essay", DependsOn: []string{"notes"}}},
+		{Deadline: types.Deadline{Name: "notes", DependsOn: []string{"essay"}}},
+	}
+	problems := checkDependencies(deadlines, nil)
+	assert.Len(t, problems, 2)
+	assert.ErrorIs(t, problems[0], ErrUnknownDependency)
+	assert.ErrorIs(t, problems[1], ErrDependencyCycle)
+	assert.Contains(t, problems[1].Error(), "essay -> notes -> essay")
+
+	deadlines[0].DependsOn = nil
+	assert.Empty(t, checkDependencies(deadlines[:2], nil))
+
+	// a deadline that is done can still be depended on
+	assert.Empty(t, checkDependencies(deadlines[1:2], []string{"report"}))
+}
+
+func TestGenerateDependencies(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "slides", MinutesRemaining: 50, DeadlineTime: now.Add(4 * time.Hour), DependsOn: []string{"report"}}},
+			{Deadline: types.Deadline{Name: "report", MinutesRemaining: 100, DeadlineTime: now.Add(8 * time.Hour)}},
+		},
+	}
+	for _, strategy := range Strategies() {
+		timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: strategy})
+		assert.NoError(t, err)
+		lastReport, firstSlides := -1, -1
+		for i, slot := range timetable.Slots {
+			if slot.Kind != DeadlineSlot {
+				continue
+			}
+			if slot.Deadline.Name == "report" {
+				lastReport = i
+			} else if firstSlides < 0 {
+				firstSlides = i
+			}
+		}
+		assert.Less(t, lastReport, firstSlides)
+		assert.Less(t, firstSlides, 8)
+		assert.True(t, strings.Contains(timetable.String(), "[DEADLINE] slides (after report)"))
+	}
+
+	// once the report is done, the slides are no longer waiting on it
+	done, err := Generate(context.Background(), &Input{Deadlines: in.Deadlines[:1]}, Options{Now: now})
+	assert.NoError(t, err)
+	assert.Contains(t, done.String(), "[DEADLINE] slides\n")
+	assert.NotContains(t, done.String(), "(after")
+
+	// there is time for the slides, but not once the report is done
+	in.Deadlines[0].MinutesRemaining = 150
+	_, err = Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, 2, infeasibleErr.SlotsShort)
+}
@@ -0,0 +1,173 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Diff is what changed in the work planned on each deadline between two timetables
+type Diff struct {
+	Deadlines []DeadlineDiff `json:"deadlines"`
+}
+
+// DeadlineDiff is what changed in the blocks of work planned on a deadline, where a block is
+// consecutive slots given to it
+type DeadlineDiff struct {
+	Deadline string `json:"deadline"`
+	Source   string `json:"source,omitempty"`
+	// Unchanged is the number of blocks planned at the same times in both
+	Unchanged int          `json:"unchanged"`
+	Moved     []MovedBlock `json:"moved,omitempty"`
+	Added     []Block      `json:"added,omitempty"`
+	Removed   []Block      `json:"removed,omitempty"`
+}
+
+// Block is consecutive slots of work on a deadline
+type Block struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Slots int       `json:"slots"`
+}
+
+// MovedBlock is a block planned at different times in the two timetables
+type MovedBlock struct {
+	From Block `json:"from"`
+	To   Block `json:"to"`
+}
+
+func (b Block) String() string {
+	return fmt.Sprintf("%s-%s (%d slot(s))", b.Start.Format("Jan 2 15:04"), b.End.Format("Jan 2 15:04"), b.Slots)
+}
+
+// Changed says whether any work moved, was added or was removed
+func (d *Diff) Changed() bool {
+	for _, deadline := range d.Deadlines {
+		if deadline.changed() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d DeadlineDiff) changed() bool {
+	return len(d.Moved) > 0 || len(d.Added) > 0 || len(d.Removed) > 0
+}
+
+// String prints the changes to each deadline whose work changed
+func (d *Diff) String() string {
+	if !d.Changed() {
+		return "No changes\n"
+	}
+	builder := strings.Builder{}
+	for _, deadline := range d.Deadlines {
+		if !deadline.changed() {
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("%s: %d block(s) unchanged\n", deadline.Deadline, deadline.Unchanged))
+		for _, moved := range deadline.Moved {
+			builder.WriteString(fmt.Sprintf("  moved %s to %s\n", moved.From, moved.To))
+		}
+		for _, added := range deadline.Added {
+			builder.WriteString(fmt.Sprintf("  added %s\n", added))
+		}
+		for _, removed := range deadline.Removed {
+			builder.WriteString(fmt.Sprintf("  removed %s\n", removed))
+		}
+	}
+	return builder.String()
+}
+
+// DiffStates compares the work planned in two timetables over the time both cover, from when
+// the later of them starts, as work before then has either been done or not planned yet, until
+// the earlier of them ends, as work after then is not known to both
+// blocks of work on a deadline at the same times in both are unchanged, and the rest are
+// paired up in order as moved, with any left over added or removed
+func DiffStates(before *State, after *State) *Diff {
+	from := before.Start
+	if after.Start.After(from) {
+		from = after.Start
+	}
+	// a zero until is no limit
+	var until time.Time
+	if before.End != nil {
+		until = *before.End
+	}
+	if after.End != nil && (until.IsZero() || after.End.Before(until)) {
+		until = *after.End
+	}
+	beforeBlocks, beforeOrder := stateBlocks(before, from, until)
+	afterBlocks, afterOrder := stateBlocks(after, from, until)
+	// deadlines in the order they are first worked on, in the new timetable and then the old
+	order := afterOrder
+	for _, key := range beforeOrder {
+		if _, ok := afterBlocks[key]; !ok {
+			order = append(order, key)
+		}
+	}
+	diff := &Diff{Deadlines: []DeadlineDiff{}}
+	for _, key := range order {
+		deadline := DeadlineDiff{Deadline: key[0], Source: key[1]}
+		var removed, added []Block
+		for _, block := range beforeBlocks[key] {
+			if containsBlock(afterBlocks[key], block) {
+				deadline.Unchanged++
+			} else {
+				removed = append(removed, block)
+			}
+		}
+		for _, block := range afterBlocks[key] {
+			if !containsBlock(beforeBlocks[key], block) {
+				added = append(added, block)
+			}
+		}
+		for len(removed) > 0 && len(added) > 0 {
+			deadline.Moved = append(deadline.Moved, MovedBlock{From: removed[0], To: added[0]})
+			removed, added = removed[1:], added[1:]
+		}
+		if len(removed) > 0 {
+			deadline.Removed = removed
+		}
+		if len(added) > 0 {
+			deadline.Added = added
+		}
+		diff.Deadlines = append(diff.Deadlines, deadline)
+	}
+	return diff
+}
+
+// stateBlocks groups the slots of work in the state ending after from, and starting before
+// until unless it is zero, into blocks, by the name and source of their deadline, along with
+// the deadlines in the order they first appear
+func stateBlocks(state *State, from time.Time, until time.Time) (blocks map[[2]string][]Block, order [][2]string) {
+	blocks = map[[2]string][]Block{}
+	var last [2]string
+	for i, slot := range state.Work {
+		if !slot.End.After(from) || (!until.IsZero() && !slot.Start.Before(until)) {
+			continue
+		}
+		key := [2]string{slot.Deadline, slot.Source}
+		if _, ok := blocks[key]; !ok {
+			order = append(order, key)
+		}
+		existing := blocks[key]
+		if i > 0 && key == last && len(existing) > 0 && existing[len(existing)-1].End.Equal(slot.Start) {
+			existing[len(existing)-1].End = slot.End
+			existing[len(existing)-1].Slots++
+		} else {
+			blocks[key] = append(existing, Block{Start: slot.Start, End: slot.End, Slots: 1})
+		}
+		last = key
+	}
+	return blocks, order
+}
+
+// containsBlock says whether a block at the same times is one of blocks
+func containsBlock(blocks []Block, block Block) bool {
+	for _, other := range blocks {
+		if other.Start.Equal(block.Start) && other.End.Equal(block.End) {
+			return true
+		}
+	}
+	return false
+}
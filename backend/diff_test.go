@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffStates(t *testing.T) {
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	slot := func(index int, name string) StateSlot {
+		return StateSlot{Start: start.Add(time.Duration(index) * 30 * time.Minute), End: start.Add(time.Duration(index+1) * 30 * time.Minute), Deadline: name, Source: ".at.toml"}
+	}
+	block := func(from, to int) Block {
+		return Block{Start: start.Add(time.Duration(from) * 30 * time.Minute), End: start.Add(time.Duration(to) * 30 * time.Minute), Slots: to - from}
+	}
+	before := &State{Start: start, Work: []StateSlot{
+		slot(0, "essay"), slot(2, "report"), slot(3, "report"), slot(5, "essay"), slot(6, "essay"), slot(8, "slides"),
+	}}
+	// half an hour on, the essay's first block has passed
+	after := &State{Start: start.Add(30 * time.Minute), Work: []StateSlot{
+		slot(2, "report"), slot(3, "report"), slot(4, "essay"), slot(5, "essay"), slot(9, "thesis"), slot(11, "report"),
+	}}
+
+	diff := DiffStates(before, after)
+	assert.Equal(t, &Diff{Deadlines: []DeadlineDiff{
+		{Deadline: "report", Source: ".at.toml", Unchanged: 1, Added: []Block{block(11, 12)}},
+		{Deadline: "essay", Source: ".at.toml", Moved: []MovedBlock{{From: block(5, 7), To: block(4, 6)}}},
+		{Deadline: "thesis", Source: ".at.toml", Added: []Block{block(9, 10)}},
+		{Deadline: "slides", Source: ".at.toml", Removed: []Block{block(8, 9)}},
+	}}, diff)
+	assert.True(t, diff.Changed())
+	assert.Contains(t, diff.String(), "essay: 0 block(s) unchanged\n  moved Jan 7 11:30-Jan 7 12:30 (2 slot(s)) to Jan 7 11:00-Jan 7 12:00 (2 slot(s))\n")
+
+	assert.Equal(t, "No changes\n", DiffStates(after, after).String())
+
+	// only the time both cover is compared, so a timetable cut short loses nothing
+	shortEnd, afterEnd := start.Add(5*time.Hour), start.Add(6*time.Hour)
+	short := &State{Start: after.Start, End: &shortEnd, Work: after.Work[:5]}
+	after.End = &afterEnd
+	assert.Equal(t, "No changes\n", DiffStates(after, short).String())
+	assert.Equal(t, "No changes\n", DiffStates(short, after).String())
+}
@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errors returned while loading the input
+var (
+	ErrNoTomls = errors.New("could not find any files called .at.toml")
+	ErrNoData  = errors.New("could not find any events or deadlines")
+	// ErrDeadlineNotFound is returned when no .at.toml sets a deadline with the name looked for
+	ErrDeadlineNotFound = errors.New("could not find a deadline with that name")
+)
+
+// problems found while validating the input, collected in a *ValidationError
+var (
+	ErrUnnamedEvent            = errors.New("found an event with no name")
+	ErrEventInPast             = errors.New("found an event that has already passed")
+	ErrEventEndsBeforeStart    = errors.New("found an event with end time before start time")
+	ErrOverlappingEvents       = errors.New("found events that overlap")
+	ErrInvalidRecurrence       = errors.New("found an event with an invalid recurrence")
+	ErrUnnamedDeadline         = errors.New("found a deadline with no name")
+	ErrNoMinutesRemaining      = errors.New("found a deadline with nonpositive minutes remaining")
+	ErrDeadlineInPast          = errors.New("found a deadline that has already passed")
+	ErrStartsAfterDeadline     = errors.New("found a deadline with start time not before the deadline")
+	ErrUnknownDependency       = errors.New("found a deadline depending on one that does not exist")
+	ErrDependencyCycle         = errors.New("found deadlines that depend on each other")
+	ErrInvalidBlocks           = errors.New("found a deadline with invalid block lengths")
+	ErrUnnamedPeriodic         = errors.New("found a periodic with no name")
+	ErrNonpositiveProbability  = errors.New("found a periodic with nonpositive probability")
+	ErrInvalidHabit            = errors.New("found a periodic with an invalid duration, count, window or spacing")
+	ErrInvalidDurations        = errors.New("found invalid slot, work or break durations")
+	ErrInvalidAvailability     = errors.New("found an invalid availability range")
+	ErrInvalidSwitchPenalty    = errors.New("found a switch penalty outside 0 to 1")
+	ErrInvalidMaxMinutesPerDay = errors.New("found a maxMinutesPerDay allowing less than a slot of work")
+)
+
+// ValidationError holds every problem found with the input, so they can all be fixed at once
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		messages[i] = problem.Error()
+	}
+	return fmt.Sprintf("found %d problem(s) with the input:\n  %s", len(e.Problems), strings.Join(messages, "\n  "))
+}
+
+// Is reports whether any of the problems is target, so errors.Is can find them
+func (e *ValidationError) Is(target error) bool {
+	for _, problem := range e.Problems {
+		if errors.Is(problem, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// validationError returns nil if there are no problems, and a *ValidationError otherwise
+func validationError(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// InfeasibleError is returned when there is too little time to meet every deadline
+type InfeasibleError struct {
+	Deadline   Deadline
+	SlotsShort int
+	// Report, if set, details the capacity for every deadline and how to make room
+	Report *CapacityReport
+}
+
+func (e *InfeasibleError) Error() string {
+	message := fmt.Sprintf("There's too little time to do everything before %s (%s)! Please reduce the number of events or deadlines or extend them to free at least %d slots", e.Deadline.DeadlineTime.Format("Jan 2 15:04"), e.Deadline.Name, e.SlotsShort)
+	if e.Report != nil {
+		message += "\n" + e.Report.String()
+	}
+	return message
+}
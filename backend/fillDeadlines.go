@@ -1,45 +1,130 @@
 package backend
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 )
 
-// fill the timetable with deadlines probabilistically
+// reasons the stochastic fill can give up
+var (
+	errAttemptsExhausted = errors.New("ran out of attempts")
+	errDegenerateWeights = errors.New("weights became too small to sample from")
+)
+
+// deadline tracks how much of a Deadline's work is left to place, and how many free
+// slots there are to place it in
+type deadline struct {
+	*Deadline
+	slotsRemaining int
+	// slotsAvailable is the number of free slots left before the deadline is due, and
+	// slotsBeforeStart the number of those before it can be started
+	slotsAvailable   int
+	slotsBeforeStart int
+	// prerequisites are the deadlines whose work has to be done first
+	prerequisites []*Deadline
+	// slotsUsable is the most slots the deadline could be given on its own, in blocks of the
+	// lengths it allows and no more than slotsPerDay a day, when the timetable is laid out
+	slotsUsable int
+	slotsPerDay int
+}
+
+// open says whether the deadline's window takes in the next free slot
+func (d deadline) open() bool {
+	return d.slotsBeforeStart == 0 && d.slotsAvailable > 0
+}
+
+// newDeadlines makes the working copies of the input's deadlines
+func newDeadlines(inputDeadlines []Deadline) []deadline {
+	deadlines := make([]deadline, len(inputDeadlines))
+	found := prerequisites(inputDeadlines)
+	for i := range inputDeadlines {
+		deadlines[i].Deadline = &inputDeadlines[i]
+		deadlines[i].prerequisites = found[i]
+	}
+	return deadlines
+}
+
+// fill the timetable with deadlines probabilistically, in at most maxAttempts passes
 // assume that it is possible
-func fillTimetable(timetable []timetableElement, deadlines []deadline) {
-	for i := 0; ; i++ {
+func fillTimetable(ctx context.Context, rng *rand.Rand, timetable []timetableElement, deadlines []deadline, maxAttempts int, switchPenalty float64) error {
+	for i := 0; i < maxAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		// need to construct a slice of weights
-		if hasFilled(timetable, deadlines, i) {
-			break
+		filled, err := hasFilled(rng, timetable, deadlines, i, switchPenalty)
+		if err != nil {
+			return fmt.Errorf("%w after %d attempts", err, i+1)
+		}
+		if filled {
+			return nil
 		}
 	}
+	return fmt.Errorf("%w after %d attempts", errAttemptsExhausted, maxAttempts)
 }
 
 // hasFilled will check if deadlines have been satisfied with a power of pow
-func hasFilled(timetable []timetableElement, deadlines []deadline, pow int) bool {
+func hasFilled(rng *rand.Rand, timetable []timetableElement, deadlines []deadline, pow int, switchPenalty float64) (bool, error) {
 	var chosenIndex int
+	var current worked
 	deadlinesCopy := copyDeadlines(deadlines)
+	// a failed attempt leaves the slots it filled, which this one may pass over
+	for i := range timetable {
+		if !timetable[i].frozen {
+			timetable[i].deadline = nil
+		}
+	}
 	for i, slot := range timetable {
-		if slot.event == nil && len(deadlinesCopy) > 0 {
-			weights := getWeights(deadlinesCopy, pow)
+		current = current.at(timetable, i)
+		if slot.free() && len(deadlinesCopy) > 0 {
+			// only the deadlines that can be worked on in the slot are sampled from
+			candidates := openDeadlines(deadlinesCopy)
+			if len(candidates) == 0 {
+				passSlot(deadlinesCopy)
+				current = current.next(nil)
+				continue
+			}
+			candidates, err := allowedCandidates(timetable, i, deadlinesCopy, candidates, current)
+			// the block lengths or daily limits can't be kept after the choices made so far
+			if err != nil {
+				return false, nil
+			}
+			if len(candidates) == 0 {
+				passSlot(deadlinesCopy)
+				current = current.next(nil)
+				continue
+			}
+			allWeights := getWeights(deadlinesCopy, pow)
+			penaliseSwitching(allWeights, deadlinesCopy, candidates, current.run, switchPenalty)
+			weights := make([]float64, len(candidates))
+			for j, candidate := range candidates {
+				weights[j] = allWeights[candidate]
+			}
 			cumulateWeights(weights)
-			r := rand.Float64() * weights[len(weights)-1]
+			// with a large enough pow, the weights overflow or underflow
+			if total := weights[len(weights)-1]; math.IsNaN(total) || math.IsInf(total, 0) || total <= 0 {
+				return false, errDegenerateWeights
+			}
+			r := rng.Float64() * weights[len(weights)-1]
 			for j, weight := range weights {
 				if r <= weight {
-					chosenIndex = j
+					chosenIndex = candidates[j]
 					break
 				}
 			}
-			timetable[i].deadline = &(deadlinesCopy[chosenIndex])
+			timetable[i].deadline = deadlinesCopy[chosenIndex].Deadline
+			current = current.next(timetable[i].deadline)
 			deadlinesCopy = reduceDeadlines(deadlinesCopy, chosenIndex)
 			if _, _, possible := possibleTimetabling(deadlinesCopy); !possible {
-				return false
+				return false, nil
 			}
 		}
 	}
 	copy(deadlines, deadlinesCopy)
-	return true
+	return true, nil
 }
 
 func copyDeadlines(deadlines []deadline) (deadlinesCopy []deadline) {
@@ -48,15 +133,32 @@ func copyDeadlines(deadlines []deadline) (deadlinesCopy []deadline) {
 	return deadlinesCopy
 }
 
+// openDeadlines lists the indices of the deadlines that can be worked on in the next free
+// slot, as it is in their window and nothing they depend on is left to do
+func openDeadlines(deadlines []deadline) (indices []int) {
+	for i, deadline := range deadlines {
+		if deadline.open() && !blocked(deadlines, i) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
 // getWeights goes over deadlines and makes a deciated float slice
 func getWeights(deadlines []deadline, pow int) (weights []float64) {
 	weights = make([]float64, len(deadlines))
 	for i, deadline := range deadlines {
-		weights[i] = float64(deadline.slotsRemaining) / math.Pow(float64(deadline.slotsAvailable), float64(pow))
+		weights[i] = priorityWeight(deadline.Priority) * float64(deadline.slotsRemaining) / math.Pow(float64(deadline.slotsAvailable), float64(pow))
 	}
 	return weights
 }
 
+// priorityWeight is what a deadline's weight is multiplied by for its priority, doubling with
+// each step up and halving with each step down
+func priorityWeight(priority int) float64 {
+	return math.Pow(2, float64(priority))
+}
+
 // cumulateWeights adds the weights as they go along
 func cumulateWeights(weights []float64) {
 	for i, weight := range weights {
@@ -68,11 +170,21 @@ func cumulateWeights(weights []float64) {
 	}
 }
 
+// passSlot moves the deadlines on past the next free slot
+func passSlot(deadlines []deadline) {
+	for i, deadline := range deadlines {
+		if deadline.slotsBeforeStart > 0 {
+			deadlines[i].slotsBeforeStart--
+		}
+		deadlines[i].slotsAvailable = deadline.slotsAvailable - 1
+	}
+}
+
 // change the deadlines so that they will delete if complete, reduce otherwise
 func reduceDeadlines(deadlines []deadline, index int) []deadline {
 	zeroFlag := false
+	passSlot(deadlines)
 	for i, deadline := range deadlines {
-		deadlines[i].slotsAvailable = deadline.slotsAvailable - 1
 		if i == index {
 			deadlines[i].slotsRemaining = deadline.slotsRemaining - 1
 			if deadlines[i].slotsRemaining <= 0 {
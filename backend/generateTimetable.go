@@ -1,82 +1,203 @@
 package backend
 
 import (
-	"hash/fnv"
+	"context"
 	"fmt"
-	"log"
+	"hash/fnv"
 	"math"
 	"math/rand"
-	"strings"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 type timetableElement struct {
-	event    *event
-	deadline *deadline
-	periodics []periodic
+	event     *Event
+	deadline  *Deadline
+	periodics []Periodic
+	// habit is the habit kept up in the slot, if any
+	habit *Periodic
+	// frozen slots keep the deadline a previous timetable gave them
+	frozen bool
+	// off slots are outside the times deadlines can be worked on
+	off bool
+	// dayStart marks the first slot of a day
+	dayStart bool
+	// dayCap is the most slots of work on deadlines in the day a dayStart slot starts, or 0
+	// for no limit
+	dayCap int
+}
+
+// free says whether a deadline can be worked on in the slot
+func (e timetableElement) free() bool {
+	return e.event == nil && e.habit == nil && !e.off && !e.frozen
 }
 
-// GenerateTimetable is the function to generate the timetable
-func GenerateTimetable(data inputData, threshold float64) {
-	timetable := getEmptyTimetable(data.Deadlines, data.Events, data.slots)
+// Generate fills the time until the last deadline or event with the input's events and
+// spreads the deadlines' work over the slots left free
+// if any events or deadlines have already passed, the error is a *ValidationError, and if the
+// deadlines cannot all be met, it is an *InfeasibleError, unless opts.BestEffort is set
+func Generate(ctx context.Context, in *Input, opts Options) (*Timetable, error) {
+	opts.SwitchPenalty = opts.switchPenalty(in.Settings)
+	if opts.SwitchPenalty < 0 || opts.SwitchPenalty > 1 {
+		return nil, validationError([]error{fmt.Errorf("%w: %v", ErrInvalidSwitchPenalty, opts.SwitchPenalty)})
+	}
+	scheduler, err := getScheduler(opts)
+	if err != nil {
+		return nil, err
+	}
+	allocate, err := getAllocator(opts)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPlan(in, opts)
+	if err != nil {
+		return nil, err
+	}
+	// keeping to the previous timetable is only worth it while every deadline can be met
+	if p.frozen > 0 && p.feasibilityError() != nil {
+		log.Warnf("planning afresh, as keeping the %d slot(s) planned before would leave too little time", p.frozen)
+		opts.Previous = nil
+		if p, err = newPlan(in, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	// if a timetabling is not possible, stop, or do as much as there is time for
+	var shortfalls []Shortfall
+	if err := p.feasibilityError(); err != nil {
+		if !opts.BestEffort {
+			return nil, err
+		}
+		shortfalls = p.bestEffort(allocate)
+	}
 
-	fillWithPeriodics(timetable, data.Periodics)
+	// otherwise, assign the deadlines to the free slots
+	seed := opts.seed(p.g.start)
+	if err := scheduler.schedule(ctx, rand.New(rand.NewSource(seed)), p.timetable, p.deadlines); err != nil {
+		return nil, err
+	}
 
-	fillWithEvents(timetable, data.Events)
+	timetable := extendTimetable(p.timetable, opts.Slots)
+	// the slots added are off outside the available times too
+	fillWithAvailability(p.g, timetable)
+	t := newTimetable(p.g, seed, timetable)
+	t.Shortfalls = shortfalls
+	return t, nil
+}
 
-	fillDeadlines(timetable, data.Deadlines)
+// plan is a timetable laid out with everything but the work on deadlines, along with how
+// much work there is for each deadline and how many free slots there are before it
+type plan struct {
+	g         grid
+	timetable []timetableElement
+	deadlines []deadline
+	// frozen is the number of slots kept from a previous timetable
+	frozen int
+}
 
-	// if a timetabling is not possible, stop
-	if time, slots, possible := possibleTimetabling(data.Deadlines); !possible {
-		log.Fatalf("There's too little time to do everything before %s! Please reduce the number of events or deadlines or extend them to free at least %d slots", time.Format("Jan 2 15:04"), slots)
+// newPlan lays out the timetable for in, once it has checked nothing has passed by the time
+// opts plans from
+func newPlan(in *Input, opts Options) (*plan, error) {
+	now := opts.now()
+	if err := validationError(checkNotPassed(in, now)); err != nil {
+		return nil, err
+	}
+	g, err := newGrid(in, opts, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := validationError(checkDeadlineCaps(g.Durations, in.Deadlines)); err != nil {
+		return nil, err
+	}
+	events, err := planEvents(g, in, opts.Slots)
+	if err != nil {
+		return nil, err
 	}
 
-	// otherwise, we loop in a random to probabilistic assignment
-	fillTimetable(timetable, data.Deadlines)
+	deadlines := newDeadlines(in.Deadlines)
+	timetable := getEmptyTimetable(g, in.Deadlines, events)
 
-	timetable = extendTimetable(timetable, data.slots)
-	fmt.Printf("%s", printTimetable(timetable, data.slots))
+	fillDays(g, timetable)
 
+	fillWithAvailability(g, timetable)
+
+	fillWithPeriodics(g, timetable, in.Periodics)
+
+	fillWithEvents(g, timetable, events)
+
+	fillWithHabits(g, timetable, in.Periodics)
+
+	frozen := fillWithFrozen(g, timetable, in.Deadlines, opts.Previous, opts.freezeHorizon(in.Settings))
+
+	fillWithDailyCap(g, timetable)
+
+	fillDeadlines(g, timetable, deadlines)
+	// deadlines whose work is all in kept slots have nothing left to plan
+	var unplanned []deadline
+	for _, deadline := range deadlines {
+		if deadline.slotsRemaining > 0 {
+			unplanned = append(unplanned, deadline)
+		}
+	}
+	return &plan{g: g, timetable: timetable, deadlines: unplanned, frozen: frozen}, nil
 }
 
-// generate a slice of timetable elements
-func getEmptyTimetable(deadlines []deadline, events []event, noOfSlots int) (timetable []timetableElement) {
-	var numberOfSpaces int
-	deadlinesEnd := len(deadlines)
-	eventsEnd := len(events)
-	if deadlinesEnd != 0 {
-		deadlinesEnd = segmentsBetween(currentTime, deadlines[deadlinesEnd-1].DeadlineTime)
+// generate a slice of timetable elements, running until the last deadline or event ends
+func getEmptyTimetable(g grid, deadlines []Deadline, events []Event) (timetable []timetableElement) {
+	timetable = make([]timetableElement, timetableLength(g, deadlines, events))
+	return timetable
+}
+
+// timetableLength is the number of slots until the last deadline or event ends
+func timetableLength(g grid, deadlines []Deadline, events []Event) int {
+	numberOfSpaces := 0
+	for _, deadline := range deadlines {
+		numberOfSpaces = int(math.Max(float64(numberOfSpaces), float64(g.floor(deadline.DeadlineTime))))
 	}
-	if eventsEnd != 0 {
-		eventsEnd = segmentsBetween(currentTime, events[eventsEnd-1].EndTime)
+	for _, event := range events {
+		if event.Recurrence != nil {
+			continue
+		}
+		numberOfSpaces = int(math.Max(float64(numberOfSpaces), float64(g.ceil(event.EndTime))))
 	}
-	numberOfSpaces = int(math.Max(float64(deadlinesEnd), float64(eventsEnd)))
-	timetable = make([]timetableElement, numberOfSpaces)
-	return timetable
+	return numberOfSpaces
 }
 
-// return the number of segments between time1 (rounded up) and time2 (rounded down)
-func segmentsBetween(time1 time.Time, time2 time.Time) int {
-	durationBetween := time2.Sub(time1)
-	return (int)(durationBetween.Minutes() / 30)
+// planEvents lists the events that take up slots of a timetable on g: the one-off events, and
+// the occurrences of recurring events until the last deadline or one-off event ends, or
+// until the first slots slots are over if that is later
+// if any occurrences overlap, the error is a *ValidationError
+func planEvents(g grid, in *Input, slots int) ([]Event, error) {
+	horizon := timetableLength(g, in.Deadlines, in.Events)
+	if slots > horizon {
+		horizon = slots
+	}
+	events := expandEvents(in.Events, g.start, g.slotStart(horizon))
+	if err := validationError(checkOverlaps(events)); err != nil {
+		return nil, err
+	}
+	return events, nil
 }
 
-// fill the timetable with the periodics
-func fillWithPeriodics(timetable []timetableElement, periodics []periodic) {
+// fill the timetable with the periodics that are not habits
+func fillWithPeriodics(g grid, timetable []timetableElement, periodics []Periodic) {
 	// for every slot, there is a chance that it will be filled with some periodics
 	for i, timetableElement := range timetable {
 		for _, periodic := range periodics {
+			if periodic.habit() {
+				continue
+			}
 			// If the random number is less than the weight
 			// assuming the probability is the "rate" the periodic occurs each day
-			if deterministicRandom(currentTime, i, periodic.Name) < (periodic.Probability / 48) {
+			if deterministicRandom(g.slotStart(i), periodic.Name) < (periodic.Probability / g.perDay()) {
 				timetable[i].periodics = append(timetableElement.periodics, periodic)
 			}
 		}
 	}
 }
 
-func deterministicRandom(currentTime time.Time, slotOffset int, periodicName string) float64 {
-	actualTime := currentTime.Add(time.Duration(slotOffset*30) * time.Minute)
+func deterministicRandom(actualTime time.Time, periodicName string) float64 {
 	// Hash the combined input using FNV-1a.
 	h := fnv.New64a()
 	h.Write([]byte(periodicName))
@@ -91,17 +212,17 @@ func deterministicRandom(currentTime time.Time, slotOffset int, periodicName str
 }
 
 // fill the timetable with the events now they are assumed to be correct
-func fillWithEvents(timetable []timetableElement, events []event) {
+func fillWithEvents(g grid, timetable []timetableElement, events []Event) {
 	var startIndex int
 	var endIndex int
 	var selectedElements []timetableElement
 	for i, event := range events {
 		// if the event is sufficiently late, break
-		if segmentsBetween(currentTime, event.StartTime) >= len(timetable) {
+		if g.floor(event.StartTime) >= len(timetable) {
 			break
 		}
-		startIndex = int(math.Max(0, float64(segmentsBetween(currentTime, event.StartTime))))
-		endIndex = int(math.Min(float64(segmentsBetween(currentTime, event.EndTime)), float64(len(timetable))))
+		startIndex = clamp(g.floor(event.StartTime), len(timetable))
+		endIndex = clamp(g.ceil(event.EndTime), len(timetable))
 		selectedElements = timetable[startIndex:endIndex]
 		for j := range selectedElements {
 			selectedElements[j].event = &(events[i])
@@ -110,45 +231,147 @@ func fillWithEvents(timetable []timetableElement, events []event) {
 }
 
 // function to fill deadlines with how many remain and are available
-func fillDeadlines(timetable []timetableElement, deadlines []deadline) {
-	var startIndex int
-	var endIndex int
-	var currentSlots int
-	startIndex = 0
-	runningTotal := 0
+func fillDeadlines(g grid, timetable []timetableElement, deadlines []deadline) {
 	for i, deadline := range deadlines {
-		deadlines[i].slotsRemaining = int(deadline.MinutesRemaining / 25)
-		endIndex = segmentsBetween(currentTime, deadline.DeadlineTime)
-		currentSlots = freeSlotsBetween(timetable[startIndex:endIndex])
-		runningTotal += currentSlots
-		deadlines[i].slotsAvailable = runningTotal
-		startIndex = endIndex
+		deadlines[i].slotsRemaining = int(math.Ceil(deadline.MinutesRemaining/g.Work.Minutes())) - frozenSlots(timetable, deadline.Deadline)
+		endIndex := clamp(g.floor(deadline.DeadlineTime), len(timetable))
+		deadlines[i].slotsAvailable = freeSlotsBetween(timetable[:endIndex])
+		if deadline.StartTime != nil {
+			startIndex := clamp(g.ceil(*deadline.StartTime), endIndex)
+			deadlines[i].slotsBeforeStart = freeSlotsBetween(timetable[:startIndex])
+		}
+		deadlines[i].slotsPerDay = g.slotsPerDay(deadline.MaxMinutesPerDay)
+	}
+	// the blocks and daily limits have to fit in the windows dependencies leave
+	narrowed, order := windows(deadlines)
+	for k, window := range narrowed {
+		i := order[k]
+		deadline := deadlines[i]
+		startIndex := afterFreeSlots(timetable, window.slotsBeforeStart)
+		endIndex := afterFreeSlots(timetable, window.slotsAvailable)
+		if endIndex < startIndex {
+			endIndex = startIndex
+		}
+		// without blocks or limits, the feasibility check narrows the window itself
+		deadlines[i].slotsUsable = deadline.slotsAvailable - deadline.slotsBeforeStart
+		// the deadline can be given no more a day than all of them can be
+		perDay := deadline.slotsPerDay
+		if g.maxSlotsPerDay > 0 && (perDay == 0 || g.maxSlotsPerDay < perDay) {
+			perDay = g.maxSlotsPerDay
+		}
+		switch {
+		case perDay > 0:
+			deadlines[i].slotsUsable = usableSlotsPerDay(timetable[startIndex:endIndex], deadline.shortestBlock(), deadline.MaxBlock, perDay)
+		case deadline.MinBlock > 1 || deadline.MaxBlock > 0:
+			deadlines[i].slotsUsable = usableSlots(timetable[startIndex:endIndex], deadline.shortestBlock(), deadline.MaxBlock)
+		}
 	}
 }
 
-// check that a timetabling is possible
-func possibleTimetabling(deadlines []deadline) (noFit time.Time, slotsToReduce int, possible bool) {
-	runningTotal := 0
-	for _, deadline := range deadlines {
-		runningTotal += deadline.slotsRemaining
-		if runningTotal > deadline.slotsAvailable {
-			return deadline.DeadlineTime, (runningTotal - deadline.slotsAvailable), false
+// check that a timetabling is possible, returning the first deadline that cannot be met and
+// how many slots it is short by if not
+func possibleTimetabling(deadlines []deadline) (noFit deadline, slotsToReduce int, possible bool) {
+	narrowed, order := windows(deadlines)
+	for i, spare := range spareSlots(narrowed, math.MaxInt32) {
+		if spare < 0 {
+			return deadlines[order[i]], -spare, false
 		}
 	}
 	return noFit, slotsToReduce, true
 }
 
+// spareSlots finds, for each deadline, the fewest free slots left over in any stretch of time
+// ending when it is due, once the work on the deadlines that must be done within the stretch
+// is done, for the stretches starting when a deadline can be started, no later than latestStart
+// a negative number is the slots there are too few of; as long as the deadlines are sorted by
+// when they are due, checking these stretches is enough to know every deadline can be met
+// within its window, and with windows narrowed for dependencies, in order too
+func spareSlots(deadlines []deadline, latestStart int) []int {
+	spares := make([]int, len(deadlines))
+	for i := range spares {
+		spares[i] = math.MaxInt32
+	}
+	for _, from := range windowStarts(deadlines) {
+		if from > latestStart {
+			continue
+		}
+		due := 0
+		for i, deadline := range deadlines {
+			if deadline.slotsBeforeStart >= from {
+				due += deadline.slotsRemaining
+			}
+			free := deadline.slotsAvailable - from
+			if free < 0 {
+				free = 0
+			}
+			if free-due < spares[i] {
+				spares[i] = free - due
+			}
+		}
+	}
+	return spares
+}
+
+// windowStarts lists the different numbers of free slots before the deadlines can be started
+func windowStarts(deadlines []deadline) (starts []int) {
+	for i, deadline := range deadlines {
+		seen := false
+		for _, earlier := range deadlines[:i] {
+			if earlier.slotsBeforeStart == deadline.slotsBeforeStart {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			starts = append(starts, deadline.slotsBeforeStart)
+		}
+	}
+	return starts
+}
+
+// feasibilityError wraps the result of possibleTimetabling in an *InfeasibleError, along with
+// a report on the capacity for every deadline
+func (p *plan) feasibilityError() error {
+	if noFit, slots, possible := possibleTimetabling(p.deadlines); !possible {
+		return &InfeasibleError{Deadline: *noFit.Deadline, SlotsShort: slots, Report: p.capacityReport()}
+	}
+	// each deadline also has to fit in its window in blocks of the lengths it allows
+	for _, deadline := range p.deadlines {
+		if deadline.slotsRemaining > deadline.slotsUsable {
+			return &InfeasibleError{Deadline: *deadline.Deadline, SlotsShort: deadline.slotsRemaining - deadline.slotsUsable, Report: p.capacityReport()}
+		}
+	}
+	// and the work on all of them has to fit in the work allowed each day
+	return p.dailyCapError()
+}
+
 // calculate the number of free slots in the timetable slice
 func freeSlotsBetween(timetablePart []timetableElement) int {
 	count := 0
 	for _, slot := range timetablePart {
-		if slot.event == nil {
+		if slot.free() {
 			count++
 		}
 	}
 	return count
 }
 
+// afterFreeSlots is the index just after the first count free slots of the timetable
+func afterFreeSlots(timetable []timetableElement, count int) int {
+	if count <= 0 {
+		return 0
+	}
+	for i, slot := range timetable {
+		if slot.free() {
+			count--
+		}
+		if count == 0 {
+			return i + 1
+		}
+	}
+	return len(timetable)
+}
+
 // function to extend the timetable, if need-be, to the number of slots given
 func extendTimetable(timetable []timetableElement, noOfSlots int) []timetableElement {
 	if noOfSlots <= len(timetable) {
@@ -158,27 +381,3 @@ func extendTimetable(timetable []timetableElement, noOfSlots int) []timetableEle
 	extraPart := make([]timetableElement, noOfSlots-len(timetable))
 	return append(timetable, extraPart...)
 }
-
-// function to print the timetable as-is
-func printTimetable(timetable []timetableElement, noOfSlots int) string {
-	builder := strings.Builder{}
-	for i := 0; i < noOfSlots; i++ {
-		switch {
-		case timetable[i].event != nil:
-			builder.WriteString(fmt.Sprintf("%s-%s: ", (currentTime.Add(time.Duration(i*30) * time.Minute)).Format("Jan 2 15:04"), (currentTime.Add(time.Duration((i+1)*30) * time.Minute)).Format("Jan 2 15:04")))
-			builder.WriteString(fmt.Sprintf("[EVENT] %s", timetable[i].event.Name))
-		case timetable[i].deadline != nil:
-			builder.WriteString(fmt.Sprintf("%s-%s: ", (currentTime.Add(time.Duration(i*30) * time.Minute)).Format("Jan 2 15:04"), (currentTime.Add(time.Duration((i+1)*30-5) * time.Minute)).Format("Jan 2 15:04")))
-			builder.WriteString(fmt.Sprintf("[DEADLINE] %s", timetable[i].deadline.Name))
-			builder.WriteString(fmt.Sprintln())
-			builder.WriteString(fmt.Sprintf("%s-%s: 5 minute break", (currentTime.Add(time.Duration((i+1)*30-5) * time.Minute)).Format("Jan 2 15:04"), (currentTime.Add(time.Duration((i+1)*30) * time.Minute)).Format("Jan 2 15:04")))
-		default:
-			builder.WriteString(fmt.Sprintf("%s-%s: FREE SLOT", (currentTime.Add(time.Duration(i*30) * time.Minute)).Format("Jan 2 15:04"), (currentTime.Add(time.Duration((i+1)*30) * time.Minute)).Format("Jan 2 15:04")))
-		}
-		for _, periodic := range timetable[i].periodics {
-			builder.WriteString(fmt.Sprintf(" ; [PERIODIC] %s", periodic.Name))
-		}
-		builder.WriteString(fmt.Sprintln())
-	}
-	return builder.String()
-}
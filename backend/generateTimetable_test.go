@@ -0,0 +1,165 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 10, 0, 0, time.UTC)
+	start := time.Date(2030, 1, 7, 9, 30, 0, 0, time.UTC)
+	in := &Input{
+		Events: []Event{
+			{Event: types.Event{Name: "meeting", StartTime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)}},
+		},
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 100, DeadlineTime: start.Add(4 * time.Hour)}},
+		},
+	}
+	timetable, err := Generate(context.Background(), in, Options{Slots: 10, Now: now})
+	assert.NoError(t, err)
+	assert.Len(t, timetable.Slots, 10)
+	assert.Equal(t, start, timetable.Start)
+	assert.Equal(t, start.Add(4*time.Hour), timetable.Slots[8].Start)
+
+	counts := map[SlotKind]int{}
+	for _, slot := range timetable.Slots {
+		counts[slot.Kind]++
+		if slot.Kind == DeadlineSlot {
+			assert.Same(t, &in.Deadlines[0], slot.Deadline)
+		}
+	}
+	assert.Equal(t, 2, counts[EventSlot])
+	assert.Equal(t, 4, counts[DeadlineSlot])
+
+	// too much work to fit in before the deadline
+	in.Deadlines[0].MinutesRemaining = 200
+	_, err = Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, 2, infeasibleErr.SlotsShort)
+
+	// by the afternoon, everything has passed
+	_, err = Generate(context.Background(), in, Options{Now: now.Add(6 * time.Hour)})
+	assert.ErrorIs(t, err, ErrDeadlineInPast)
+}
+
+func TestGenerateSeed(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 250, DeadlineTime: now.Add(24 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "slides", MinutesRemaining: 250, DeadlineTime: now.Add(48 * time.Hour)}},
+		},
+	}
+	names := func(timetable *Timetable) (names []string) {
+		for _, slot := range timetable.Slots {
+			if slot.Deadline != nil {
+				names = append(names, slot.Deadline.Name)
+			} else {
+				names = append(names, "")
+			}
+		}
+		return names
+	}
+
+	seed := int64(42)
+	first, err := Generate(context.Background(), in, Options{Now: now, Seed: &seed})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), first.Seed)
+	second, err := Generate(context.Background(), in, Options{Now: now, Seed: &seed})
+	assert.NoError(t, err)
+	assert.Equal(t, names(first), names(second))
+
+	defaulted, err := Generate(context.Background(), in, Options{Now: now})
+	assert.NoError(t, err)
+	assert.Equal(t, now.Unix(), defaulted.Seed)
+
+	// a seed of 0 can be given like any other
+	seed = 0
+	zero, err := Generate(context.Background(), in, Options{Now: now, Seed: &seed})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), zero.Seed)
+}
+
+func TestGenerateDurations(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 20, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 100, DeadlineTime: now.Add(4 * time.Hour)}},
+		},
+		Settings: types.Settings{WorkMinutes: 50, BreakMinutes: 10},
+	}
+	timetable, err := Generate(context.Background(), in, Options{Now: now})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2030, 1, 7, 10, 0, 0, 0, time.UTC), timetable.Start)
+	// 100 minutes of 50 minute pomodoros, in the three hour-long slots before the deadline
+	assert.Len(t, timetable.Slots, 3)
+	deadlineSlots := 0
+	for _, slot := range timetable.Slots {
+		assert.Equal(t, time.Hour, slot.End.Sub(slot.Start))
+		if slot.Kind == DeadlineSlot {
+			deadlineSlots++
+			assert.Equal(t, 50*time.Minute, slot.WorkEnd.Sub(slot.Start))
+		}
+	}
+	assert.Equal(t, 2, deadlineSlots)
+
+	// options override the settings
+	timetable, err = Generate(context.Background(), in, Options{Now: now, Durations: Durations{Work: 25 * time.Minute, Break: 5 * time.Minute}})
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, timetable.Slots[0].End.Sub(timetable.Slots[0].Start))
+
+	_, err = Generate(context.Background(), in, Options{Now: now, Durations: Durations{Slot: 30 * time.Minute}})
+	assert.ErrorIs(t, err, ErrInvalidDurations)
+}
+
+func TestGenerateStartTime(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	afternoon := now.Add(4 * time.Hour)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "review", MinutesRemaining: 100, StartTime: &afternoon, DeadlineTime: now.Add(6 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 100, DeadlineTime: now.Add(8 * time.Hour)}},
+		},
+	}
+	for _, strategy := range Strategies() {
+		timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: strategy})
+		assert.NoError(t, err)
+		// the window is only just long enough for the review
+		for _, slot := range timetable.Slots[8:12] {
+			assert.Equal(t, "review", slot.Deadline.Name)
+		}
+		reviews := 0
+		for _, slot := range timetable.Slots {
+			if slot.Deadline != nil && slot.Deadline.Name == "review" {
+				reviews++
+			}
+		}
+		assert.Equal(t, 4, reviews)
+	}
+
+	// there are enough slots before the notes are due, but not in their window
+	in.Deadlines = append(in.Deadlines, Deadline{Deadline: types.Deadline{Name: "notes", MinutesRemaining: 50, StartTime: &afternoon, DeadlineTime: now.Add(6 * time.Hour)}})
+	sortData(in)
+	_, err := Generate(context.Background(), in, Options{Now: now})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Equal(t, "notes", infeasibleErr.Deadline.Name)
+	assert.Equal(t, 2, infeasibleErr.SlotsShort)
+	assert.Equal(t, 4, infeasibleErr.Report.Deadlines[1].WindowFreeSlots)
+
+	timetable, err := Generate(context.Background(), in, Options{Now: now, BestEffort: true})
+	assert.NoError(t, err)
+	assert.Len(t, timetable.Shortfalls, 1)
+	assert.Equal(t, "notes", timetable.Shortfalls[0].Deadline.Name)
+	assert.Equal(t, 50.0, timetable.Shortfalls[0].Minutes)
+
+	problems := checkDeadlines([]Deadline{{Deadline: types.Deadline{Name: "late", MinutesRemaining: 25, StartTime: &afternoon, DeadlineTime: now}}})
+	assert.Len(t, problems, 1)
+	assert.ErrorIs(t, problems[0], ErrStartsAfterDeadline)
+}
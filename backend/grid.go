@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+)
+
+// Durations are the length of a slot, and of the work and break a deadline's slot is split into
+type Durations struct {
+	Slot  time.Duration
+	Work  time.Duration
+	Break time.Duration
+}
+
+// DefaultDurations are half-hour slots of 25 minutes' work followed by a 5 minute break
+var DefaultDurations = Durations{Slot: 30 * time.Minute, Work: 25 * time.Minute, Break: 5 * time.Minute}
+
+// settingsDurations reads the durations given in the settings table
+func settingsDurations(settings types.Settings) Durations {
+	return Durations{
+		Slot:  time.Duration(settings.SlotMinutes) * time.Minute,
+		Work:  time.Duration(settings.WorkMinutes) * time.Minute,
+		Break: time.Duration(settings.BreakMinutes) * time.Minute,
+	}
+}
+
+// override replaces any durations that are set in overrides
+func (d Durations) override(overrides Durations) Durations {
+	if overrides.Slot != 0 {
+		d.Slot = overrides.Slot
+	}
+	if overrides.Work != 0 {
+		d.Work = overrides.Work
+	}
+	if overrides.Break != 0 {
+		d.Break = overrides.Break
+	}
+	return d
+}
+
+// withDefaults fills in any durations not set, making work and a break fill a slot
+// where possible
+func (d Durations) withDefaults() Durations {
+	if d.Slot == 0 && d.Work == 0 && d.Break == 0 {
+		return DefaultDurations
+	}
+	if d.Slot != 0 && d.Work != 0 {
+		if d.Break == 0 {
+			d.Break = d.Slot - d.Work
+		}
+		return d
+	}
+	if d.Break == 0 {
+		d.Break = DefaultDurations.Break
+	}
+	switch {
+	case d.Slot == 0 && d.Work == 0:
+		d.Work = DefaultDurations.Work
+		d.Slot = d.Work + d.Break
+	case d.Slot == 0:
+		d.Slot = d.Work + d.Break
+	default:
+		d.Work = d.Slot - d.Break
+	}
+	return d
+}
+
+// check will ensure the work and break are positive and fit in a slot of at most a day
+func (d Durations) check() (problems []error) {
+	if d.Slot <= 0 || d.Slot > 24*time.Hour {
+		problems = append(problems, fmt.Errorf("%w: slots of %s must be longer than zero and at most a day", ErrInvalidDurations, d.Slot))
+	}
+	if d.Work <= 0 || d.Break < 0 {
+		problems = append(problems, fmt.Errorf("%w: %s of work and %s of break must be positive", ErrInvalidDurations, d.Work, d.Break))
+	}
+	if d.Work+d.Break > d.Slot {
+		problems = append(problems, fmt.Errorf("%w: %s of work and %s of break do not fit in a %s slot", ErrInvalidDurations, d.Work, d.Break, d.Slot))
+	}
+	return problems
+}
+
+// perDay is the number of slots in a day
+func (d Durations) perDay() float64 {
+	return float64(24*time.Hour) / float64(d.Slot)
+}
+
+// grid lays the slots of a timetable out from its start
+type grid struct {
+	start time.Time
+	Durations
+	availability availability
+	// maxSlotsPerDay is the most slots of work on deadlines in a day, or 0 for no limit
+	maxSlotsPerDay int
+}
+
+// newGrid works out the durations for in and opts, and starts the grid at the first slot
+// after now
+func newGrid(in *Input, opts Options, now time.Time) (grid, error) {
+	durations := settingsDurations(in.Settings).override(opts.Durations).withDefaults()
+	problems := durations.check()
+	av, availabilityProblems := newAvailability(in.Availability)
+	problems = append(problems, availabilityProblems...)
+	maxMinutesPerDay := in.Settings.MaxMinutesPerDay
+	if opts.MaxMinutesPerDay != 0 {
+		maxMinutesPerDay = opts.MaxMinutesPerDay
+	}
+	if len(problems) == 0 {
+		problems = durations.checkMaxMinutesPerDay(maxMinutesPerDay, "the settings")
+	}
+	if err := validationError(problems); err != nil {
+		return grid{}, err
+	}
+	return grid{start: roundUp(now, durations.Slot), Durations: durations, availability: av, maxSlotsPerDay: durations.slotsPerDay(maxMinutesPerDay)}, nil
+}
+
+// slotStart is the time the slot at index starts
+func (g grid) slotStart(index int) time.Time {
+	return g.start.Add(time.Duration(index) * g.Slot)
+}
+
+// floor is the index of the slot t falls in, so the number of whole slots before t
+func (g grid) floor(t time.Time) int {
+	between := t.Sub(g.start)
+	index := between / g.Slot
+	if between%g.Slot < 0 {
+		index--
+	}
+	return int(index)
+}
+
+// ceil is the index of the first slot starting at or after t
+func (g grid) ceil(t time.Time) int {
+	index := g.floor(t)
+	if g.slotStart(index).Before(t) {
+		index++
+	}
+	return index
+}
+
+// clamp restricts an index to the n slots of a timetable
+func clamp(index int, n int) int {
+	if index < 0 {
+		return 0
+	}
+	if index > n {
+		return n
+	}
+	return index
+}
+
+// roundUp rounds a time up to its nearest slot boundary
+func roundUp(unrounded time.Time, slot time.Duration) (rounded time.Time) {
+	rounded = unrounded.Truncate(slot)
+	if unrounded == rounded {
+		return rounded
+	}
+	return rounded.Add(slot)
+}
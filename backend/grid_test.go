@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationsWithDefaults(t *testing.T) {
+	minutes := func(slot, work, rest int) Durations {
+		return Durations{Slot: time.Duration(slot) * time.Minute, Work: time.Duration(work) * time.Minute, Break: time.Duration(rest) * time.Minute}
+	}
+	assert.Equal(t, DefaultDurations, Durations{}.withDefaults())
+	assert.Equal(t, minutes(60, 50, 10), minutes(0, 50, 10).withDefaults())
+	assert.Equal(t, minutes(60, 55, 5), minutes(60, 0, 0).withDefaults())
+	assert.Equal(t, minutes(120, 90, 30), minutes(120, 90, 0).withDefaults())
+	assert.Equal(t, minutes(120, 90, 15), minutes(120, 90, 15).withDefaults())
+	assert.Equal(t, minutes(95, 90, 5), minutes(0, 90, 0).withDefaults())
+
+	assert.Empty(t, minutes(120, 90, 15).check())
+	assert.NotEmpty(t, minutes(60, 50, 15).check())
+	assert.NotEmpty(t, minutes(0, 0, 0).check())
+}
+
+func TestGrid(t *testing.T) {
+	g := grid{start: time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC), Durations: minutesGrid(60)}
+	assert.Equal(t, 0, g.floor(g.start.Add(59*time.Minute)))
+	assert.Equal(t, 1, g.ceil(g.start.Add(time.Minute)))
+	assert.Equal(t, 1, g.ceil(g.start.Add(time.Hour)))
+	assert.Equal(t, -1, g.floor(g.start.Add(-time.Minute)))
+	assert.Equal(t, 0, g.ceil(g.start.Add(-time.Minute)))
+	assert.Equal(t, g.start.Add(3*time.Hour), g.slotStart(3))
+}
+
+func minutesGrid(slot int) Durations {
+	return Durations{Slot: time.Duration(slot) * time.Minute, Work: time.Duration(slot-10) * time.Minute, Break: 10 * time.Minute}
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// habit says whether the periodic takes up time in free slots, rather than happening at random
+func (p Periodic) habit() bool {
+	return p.DurationMinutes > 0
+}
+
+// checkHabit will ensure a habit has one count, a spacing that is not negative, and valid windows
+func checkHabit(periodic Periodic) (problems []error) {
+	if periodic.PerDay < 0 || periodic.PerWeek < 0 || (periodic.PerDay > 0) == (periodic.PerWeek > 0) {
+		problems = append(problems, fmt.Errorf("%w: %s needs one of perDay and perWeek", ErrInvalidHabit, periodic.Name))
+	}
+	if periodic.MinSpacingMinutes < 0 {
+		problems = append(problems, fmt.Errorf("%w: %s has a negative minSpacingMinutes", ErrInvalidHabit, periodic.Name))
+	}
+	_, windowProblems := parseTimeRanges(periodic.Windows, ErrInvalidHabit)
+	return append(problems, windowProblems...)
+}
+
+// habitWindows says which slots a habit can be kept up in: those in its windows on any day,
+// or the available ones if it has none
+func habitWindows(g grid, periodic Periodic) availability {
+	if len(periodic.Windows) == 0 {
+		return g.availability
+	}
+	ranges, _ := parseTimeRanges(periodic.Windows, ErrInvalidHabit)
+	av := availability{set: true}
+	for day := range av.days {
+		av.days[day] = ranges
+	}
+	return av
+}
+
+// habitPeriod is a day or week of a timetable, from the slot at start up to the one at end,
+// with share the part of the day or week the timetable covers
+type habitPeriod struct {
+	start, end int
+	share      float64
+}
+
+// habitPeriods splits the timetable into days, or into weeks from Monday if weekly is set
+func habitPeriods(g grid, timetable []timetableElement, weekly bool) (periods []habitPeriod) {
+	for i := 0; i < len(timetable); {
+		from := g.slotStart(i)
+		year, month, day := from.Date()
+		periodStart := time.Date(year, month, day, 0, 0, 0, 0, from.Location())
+		days := 1
+		if weekly {
+			periodStart = periodStart.AddDate(0, 0, -(int(periodStart.Weekday())+6)%7)
+			days = 7
+		}
+		periodEnd := periodStart.AddDate(0, 0, days)
+		end := clamp(g.ceil(periodEnd), len(timetable))
+		until := g.slotStart(end)
+		if until.After(periodEnd) {
+			until = periodEnd
+		}
+		share := float64(until.Sub(from)) / float64(periodEnd.Sub(periodStart))
+		periods = append(periods, habitPeriod{start: i, end: end, share: share})
+		i = end
+	}
+	return periods
+}
+
+// fillWithHabits places the occurrences of each habit in slots without events, spread over
+// each day or week and at least its spacing apart, so deadlines are only worked on around them
+// a day or week the timetable only covers part of gets that part of its occurrences
+func fillWithHabits(g grid, timetable []timetableElement, periodics []Periodic) {
+	for i := range periodics {
+		habit := &periodics[i]
+		if !habit.habit() {
+			continue
+		}
+		length := int(math.Ceil(float64(time.Duration(habit.DurationMinutes)*time.Minute) / float64(g.Slot)))
+		spacing := int(math.Ceil(float64(time.Duration(habit.MinSpacingMinutes)*time.Minute) / float64(g.Slot)))
+		if spacing < length {
+			spacing = length
+		}
+		windows := habitWindows(g, *habit)
+		var occurrences []int
+		// fits says whether an occurrence can start at index and be over by end
+		fits := func(index int, end int) bool {
+			if index+length > end {
+				return false
+			}
+			for _, occurrence := range occurrences {
+				if index-occurrence < spacing && occurrence-index < spacing {
+					return false
+				}
+			}
+			for j := index; j < index+length; j++ {
+				if timetable[j].event != nil || timetable[j].habit != nil || !windows.available(g.slotStart(j), g.slotStart(j+1)) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, period := range habitPeriods(g, timetable, habit.PerWeek > 0) {
+			due := int(math.Round(float64(habit.PerDay+habit.PerWeek) * period.share))
+			var starts []int
+			for j := period.start; j < period.end; j++ {
+				if fits(j, period.end) {
+					starts = append(starts, j)
+				}
+			}
+			placed := 0
+			for k := 0; k < due && len(starts) > 0; k++ {
+				// aim for the middle of the kth equal part of where it could start, and
+				// failing that, the first place after or before it that still fits
+				aim := (2*k + 1) * len(starts) / (2 * due)
+				for _, start := range append(append([]int{}, starts[aim:]...), starts[:aim]...) {
+					if fits(start, period.end) {
+						for j := start; j < start+length; j++ {
+							timetable[j].habit = habit
+						}
+						occurrences = append(occurrences, start)
+						placed++
+						break
+					}
+				}
+			}
+			if placed < due {
+				log.Warnf("could only fit %d of the %d occurrences of %s due from %s", placed, due, habit.Name, g.slotStart(period.start).Format("Jan 2 15:04"))
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPeriodics(t *testing.T) {
+	tests := []struct {
+		name     string
+		periodic types.Periodic
+		problem  error
+	}{
+		{"random", types.Periodic{Name: "walk", Probability: 2}, nil},
+		{"no probability", types.Periodic{Name: "walk"}, ErrNonpositiveProbability},
+		{"habit", types.Periodic{Name: "run", DurationMinutes: 30, PerWeek: 3, Windows: []string{"07:00-09:00"}, MinSpacingMinutes: 1440}, nil},
+		{"count without duration", types.Periodic{Name: "run", Probability: 1, PerDay: 1}, ErrInvalidHabit},
+		{"no count", types.Periodic{Name: "run", DurationMinutes: 30}, ErrInvalidHabit},
+		{"both counts", types.Periodic{Name: "run", DurationMinutes: 30, PerDay: 1, PerWeek: 3}, ErrInvalidHabit},
+		{"invalid window", types.Periodic{Name: "run", DurationMinutes: 30, PerDay: 1, Windows: []string{"09:00-07:00"}}, ErrInvalidHabit},
+		{"negative spacing", types.Periodic{Name: "run", DurationMinutes: 30, PerDay: 1, MinSpacingMinutes: -1}, ErrInvalidHabit},
+	}
+	for _, test := range tests {
+		err := validationError(checkPeriodics([]Periodic{{Periodic: test.periodic}}))
+		if test.problem == nil {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.ErrorIs(t, err, test.problem, test.name)
+		}
+	}
+}
+
+func TestGenerateHabits(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 500, DeadlineTime: now.Add(3 * 24 * time.Hour)}},
+		},
+		Periodics: []Periodic{
+			{Periodic: types.Periodic{Name: "run", DurationMinutes: 60, PerDay: 1, Windows: []string{"07:00-10:00"}}},
+			{Periodic: types.Periodic{Name: "read", DurationMinutes: 30, PerDay: 2, MinSpacingMinutes: 240}},
+		},
+	}
+	timetable, err := Generate(context.Background(), in, Options{Now: now})
+	assert.NoError(t, err)
+
+	// the first day is covered from 09:00 and the last until 09:00, so gets part of each habit
+	runs, reads := map[int]int{}, map[int]int{}
+	var readStarts []time.Time
+	for _, slot := range timetable.Slots {
+		if slot.Kind != HabitSlot {
+			continue
+		}
+		switch slot.Habit.Name {
+		case "run":
+			runs[slot.Start.YearDay()]++
+			assert.True(t, slot.Start.Hour() >= 7 && slot.End.Hour() <= 10, slot.Start)
+		case "read":
+			reads[slot.Start.YearDay()]++
+			readStarts = append(readStarts, slot.Start)
+		}
+	}
+	assert.Equal(t, map[int]int{7: 2, 8: 2, 9: 2}, runs)
+	assert.Equal(t, map[int]int{7: 1, 8: 2, 9: 2, 10: 1}, reads)
+	for i := 1; i < len(readStarts); i++ {
+		assert.GreaterOrEqual(t, readStarts[i].Sub(readStarts[i-1]), 4*time.Hour)
+	}
+
+	// habits take up slots deadlines could otherwise be worked in
+	report, err := Check(in, Options{Now: now})
+	assert.NoError(t, err)
+	assert.Equal(t, 3*48-12, report.Deadlines[0].TotalFreeSlots)
+	assert.Equal(t, []EventCapacity{{Name: "run", Slots: 6}, {Name: "read", Slots: 6}}, report.Deadlines[0].Habits)
+}
@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsTimeFormat is the UTC date-time form used in iCalendar files
+const icsTimeFormat = "20060102T150405Z"
+
+// icsProdID identifies the iCalendar files this program writes
+const icsProdID = "-//mhbardsley//auto-timetable//EN"
+
+// icsLineLength is the most octets allowed on a line before it must be folded
+const icsLineLength = 75
+
+// WriteICS writes the work on deadlines and the habits in the timetable to w as an iCalendar
+// (RFC 5545) file, with consecutive slots for the same deadline or habit as one event, and
+// breaks as their own events if includeBreaks is set, between the work either side of them
+func (t *Timetable) WriteICS(w io.Writer, includeBreaks bool) error {
+	writer := icsWriter{w: bufio.NewWriter(w)}
+	writer.line("BEGIN:VCALENDAR")
+	writer.line("VERSION:2.0")
+	writer.line("PRODID:" + icsProdID)
+	writer.line("CALSCALE:GREGORIAN")
+	for _, block := range t.workBlocks(includeBreaks) {
+		first, last := block[0], block[len(block)-1]
+		if first.Kind == HabitSlot {
+			description := "habit"
+			if first.Habit.Source != "" {
+				description += ", set in " + first.Habit.Source
+			}
+			writer.event(icsUID(first.Habit.Name, first.Start), t.Start, first.Start, last.End, first.Habit.Name, description)
+			continue
+		}
+		description := fmt.Sprintf("%d slot(s) of work", len(block))
+		if first.Deadline.Source != "" {
+			description += ", set in " + first.Deadline.Source
+		}
+		writer.event(icsUID(first.Deadline.Name, first.Start), t.Start, first.Start, last.WorkEnd, first.Deadline.Name, description)
+		if !includeBreaks {
+			continue
+		}
+		for _, slot := range block {
+			if slot.BreakEnd.After(slot.WorkEnd) {
+				writer.event(icsUID("break "+slot.Deadline.Name, slot.WorkEnd), t.Start, slot.WorkEnd, slot.BreakEnd, "Break", "")
+			}
+		}
+	}
+	writer.line("END:VCALENDAR")
+	if writer.err != nil {
+		return writer.err
+	}
+	return writer.w.Flush()
+}
+
+// workBlocks groups consecutive slots given to the same deadline or habit, starting a new
+// group after each break if splitAtBreaks is set
+func (t *Timetable) workBlocks(splitAtBreaks bool) (blocks [][]Slot) {
+	for i, slot := range t.Slots {
+		if slot.Kind != DeadlineSlot && slot.Kind != HabitSlot {
+			continue
+		}
+		if i > 0 && t.Slots[i-1].Kind == slot.Kind && t.Slots[i-1].Deadline == slot.Deadline && t.Slots[i-1].Habit == slot.Habit &&
+			!(splitAtBreaks && slot.Kind == DeadlineSlot && t.Slots[i-1].BreakEnd.After(t.Slots[i-1].WorkEnd)) {
+			blocks[len(blocks)-1] = append(blocks[len(blocks)-1], slot)
+			continue
+		}
+		blocks = append(blocks, []Slot{slot})
+	}
+	return blocks
+}
+
+// icsUID makes a UID that stays the same for the same name at the same time, so that
+// importing a timetable again updates events rather than duplicating them
+func icsUID(name string, start time.Time) string {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	h.Write([]byte(start.UTC().Format(icsTimeFormat)))
+	return fmt.Sprintf("%016x@auto-timetable", h.Sum64())
+}
+
+// icsWriter writes folded iCalendar content lines, keeping the first error
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (iw *icsWriter) event(uid string, stamp, start, end time.Time, summary, description string) {
+	iw.line("BEGIN:VEVENT")
+	iw.line("UID:" + uid)
+	iw.line("DTSTAMP:" + stamp.UTC().Format(icsTimeFormat))
+	iw.line("DTSTART:" + start.UTC().Format(icsTimeFormat))
+	iw.line("DTEND:" + end.UTC().Format(icsTimeFormat))
+	iw.line("SUMMARY:" + icsEscape(summary))
+	if description != "" {
+		iw.line("DESCRIPTION:" + icsEscape(description))
+	}
+	iw.line("END:VEVENT")
+}
+
+// line writes a content line, folding it so no line is longer than icsLineLength octets
+func (iw *icsWriter) line(content string) {
+	if iw.err != nil {
+		return
+	}
+	limit := icsLineLength
+	for len(content) > limit {
+		// don't split a character across lines
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		_, iw.err = iw.w.WriteString(content[:cut] + "\r\n ")
+		content = content[cut:]
+		// the leading space of a continuation line counts towards its length
+		limit = icsLineLength - 1
+	}
+	if iw.err == nil {
+		_, iw.err = iw.w.WriteString(content + "\r\n")
+	}
+}
+
+// icsEscape escapes text values as RFC 5545 requires
+var icsEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace
@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteICS(t *testing.T) {
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	g := grid{start: start, Durations: DefaultDurations}
+	essay := &Deadline{Deadline: types.Deadline{Name: "essay, part 1"}}
+	slides := &Deadline{Deadline: types.Deadline{Name: strings.Repeat("slides ", 12)}}
+	timetable := newTimetable(g, 1, []timetableElement{{deadline: essay}, {deadline: essay}, {}, {deadline: essay}, {deadline: slides}})
+
+	var out bytes.Buffer
+	assert.NoError(t, timetable.WriteICS(&out, false))
+	written := out.String()
+	lines := strings.Split(strings.TrimSuffix(written, "\r\n"), "\r\n")
+	assert.Equal(t, "BEGIN:VCALENDAR", lines[0])
+	assert.Equal(t, "END:VCALENDAR", lines[len(lines)-1])
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), icsLineLength)
+	}
+
+	// the first two slots are merged, and the other essay slot is separate
+	assert.Equal(t, 3, strings.Count(written, "BEGIN:VEVENT"))
+	assert.Contains(t, written, "DTSTART:20300107T090000Z\r\nDTEND:20300107T095500Z\r\nSUMMARY:essay\\, part 1\r\n")
+	assert.Contains(t, written, "DTSTART:20300107T103000Z\r\nDTEND:20300107T105500Z\r\n")
+	assert.Contains(t, written, "SUMMARY:slides slides slides slides slides slides slides slides slides slid\r\n es slides slides \r\nDESCRIPTION:1 slot(s) of work\r\n")
+
+	// the same slots give the same UIDs
+	var again bytes.Buffer
+	assert.NoError(t, timetable.WriteICS(&again, false))
+	assert.Equal(t, written, again.String())
+
+	out.Reset()
+	assert.NoError(t, timetable.WriteICS(&out, true))
+	// with the breaks, the work either side of them is separate, so no events overlap
+	assert.Equal(t, 4+4, strings.Count(out.String(), "BEGIN:VEVENT"))
+	assert.Contains(t, out.String(), "DTSTART:20300107T090000Z\r\nDTEND:20300107T092500Z\r\n")
+	assert.Contains(t, out.String(), "DTSTART:20300107T092500Z\r\nDTEND:20300107T093000Z\r\nSUMMARY:Break\r\n")
+}
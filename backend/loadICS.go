@@ -0,0 +1,398 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// icsDateFormat and icsLocalTimeFormat are the date and floating date-time forms used in
+// iCalendar files
+const (
+	icsDateFormat      = "20060102"
+	icsLocalTimeFormat = "20060102T150405"
+)
+
+// icsUnnamed names imported events that have no summary
+const icsUnnamed = "Busy"
+
+// getCalendarEvents reads the events from every .ics file under dir and in icsPaths
+func getCalendarEvents(dir string, icsPaths []string) ([]Event, error) {
+	found, err := getICSs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not find .ics files: %w", err)
+	}
+	var events []Event
+	for _, icsPath := range append(found, icsPaths...) {
+		file, err := os.Open(icsPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not open ics file %s: %w", icsPath, err)
+		}
+		calendarEvents, err := parseICS(file, icsPath)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read ics file %s: %w", icsPath, err)
+		}
+		events = append(events, calendarEvents...)
+	}
+	return events, nil
+}
+
+// getICSs finds all .ics files in the file hierarchy under dir
+func getICSs(dir string) ([]string, error) {
+	var icss []string
+	err := filepath.WalkDir(dir, func(s string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(d.Name()), ".ics") {
+			icss = append(icss, s)
+		}
+		return nil
+	})
+	return icss, err
+}
+
+// icsProperty is a content line of an iCalendar file
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsEvent is the properties of a VEVENT we use
+type icsEvent struct {
+	properties map[string][]icsProperty
+}
+
+func (e icsEvent) get(name string) (icsProperty, bool) {
+	properties := e.properties[name]
+	if len(properties) == 0 {
+		return icsProperty{}, false
+	}
+	return properties[0], true
+}
+
+// parseICS reads the events from an iCalendar file, with those that are cancelled or do
+// not block time left out, and nothing read from timetables this program exported
+func parseICS(r io.Reader, source string) ([]Event, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+	var components []string
+	var icsEvents []icsEvent
+	for _, line := range lines {
+		property, err := parseICSLine(line)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case property.name == "BEGIN":
+			components = append(components, strings.ToUpper(property.value))
+			if components[len(components)-1] == "VEVENT" {
+				icsEvents = append(icsEvents, icsEvent{properties: map[string][]icsProperty{}})
+			}
+		case property.name == "END":
+			if len(components) > 0 {
+				components = components[:len(components)-1]
+			}
+		case property.name == "PRODID" && strings.Contains(property.value, icsProdID):
+			log.Infof("skipping ics file %s, which is an exported timetable", source)
+			return nil, nil
+		case len(components) > 0 && components[len(components)-1] == "VEVENT":
+			current := icsEvents[len(icsEvents)-1]
+			current.properties[property.name] = append(current.properties[property.name], property)
+		}
+	}
+
+	// occurrences moved or changed are their own VEVENTs, which replace those of the
+	// recurring event with the same UID
+	replaced := map[string][]time.Time{}
+	for _, icsEvent := range icsEvents {
+		uid, _ := icsEvent.get("UID")
+		if recurrenceID, ok := icsEvent.get("RECURRENCE-ID"); ok {
+			if t, _, err := parseICSTime(recurrenceID); err == nil {
+				replaced[uid.value] = append(replaced[uid.value], t)
+			}
+		}
+	}
+	var events []Event
+	for _, icsEvent := range icsEvents {
+		event, ok, err := icsEvent.toEvent(source)
+		if err != nil {
+			log.Warnf("skipping event in ics file %s: %s", source, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if _, isReplacement := icsEvent.get("RECURRENCE-ID"); !isReplacement && event.Recurrence != nil {
+			uid, _ := icsEvent.get("UID")
+			event.Recurrence.Exceptions = append(event.Recurrence.Exceptions, replaced[uid.value]...)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// unfoldICS splits an iCalendar file into its content lines, joining those folded over
+// several lines
+func unfoldICS(r io.Reader) (lines []string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseICSLine splits a content line into its name, parameters and value
+func parseICSLine(line string) (icsProperty, error) {
+	// the value starts after the first colon that is not in a quoted parameter
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icsProperty{}, fmt.Errorf("malformed line %q", line)
+	}
+	parts := strings.Split(line[:colon], ";")
+	property := icsProperty{name: strings.ToUpper(parts[0]), params: map[string]string{}, value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		if keyValue := strings.SplitN(param, "=", 2); len(keyValue) == 2 {
+			property.params[strings.ToUpper(keyValue[0])] = strings.Trim(keyValue[1], `"`)
+		}
+	}
+	return property, nil
+}
+
+// toEvent turns a VEVENT into an Event, which is not ok if the VEVENT does not take up time
+func (e icsEvent) toEvent(source string) (event Event, ok bool, err error) {
+	if status, _ := e.get("STATUS"); strings.EqualFold(status.value, "CANCELLED") {
+		return event, false, nil
+	}
+	if transparency, _ := e.get("TRANSP"); strings.EqualFold(transparency.value, "TRANSPARENT") {
+		return event, false, nil
+	}
+	start, ok := e.get("DTSTART")
+	if !ok {
+		return event, false, fmt.Errorf("event has no start")
+	}
+	startTime, allDay, err := parseICSTime(start)
+	if err != nil {
+		return event, false, err
+	}
+	var endTime time.Time
+	if end, ok := e.get("DTEND"); ok {
+		if endTime, _, err = parseICSTime(end); err != nil {
+			return event, false, err
+		}
+	} else if duration, ok := e.get("DURATION"); ok {
+		length, err := parseICSDuration(duration.value)
+		if err != nil {
+			return event, false, err
+		}
+		// days and weeks are nominal, so they keep to the same time of day across daylight saving
+		endTime = startTime.AddDate(0, 0, length.days).Add(length.exact)
+	} else if allDay {
+		endTime = startTime.AddDate(0, 0, 1)
+	} else {
+		endTime = startTime
+	}
+
+	name := icsUnnamed
+	if summary, ok := e.get("SUMMARY"); ok && summary.value != "" {
+		name = icsUnescape(summary.value)
+	}
+	event = Event{
+		Event:    types.Event{Name: name, StartTime: startTime, EndTime: endTime},
+		Source:   source,
+		imported: true,
+	}
+	if rule, ok := e.get("RRULE"); ok {
+		if event.Recurrence, err = parseRRule(rule.value, startTime.Location()); err != nil {
+			return event, false, fmt.Errorf("%s: %w", name, err)
+		}
+		if _, problems := newRecurrence(*event.Recurrence); len(problems) > 0 {
+			return event, false, fmt.Errorf("%s: %w", name, problems[0])
+		}
+		for _, exdate := range e.properties["EXDATE"] {
+			exceptions, err := parseICSTimes(exdate)
+			if err != nil {
+				return event, false, fmt.Errorf("%s: %w", name, err)
+			}
+			event.Recurrence.Exceptions = append(event.Recurrence.Exceptions, exceptions...)
+		}
+	}
+	return event, true, nil
+}
+
+// parseICSTime reads a date or date-time property, in UTC, the time zone named by its
+// TZID or else local time; dates, for all-day events, are midnight local time
+func parseICSTime(property icsProperty) (t time.Time, allDay bool, err error) {
+	times, err := parseICSTimes(property)
+	if err != nil {
+		return t, false, err
+	}
+	return times[0], property.params["VALUE"] == "DATE" || len(property.value) == len(icsDateFormat), nil
+}
+
+// parseICSTimes reads a property that may list several dates or date-times
+func parseICSTimes(property icsProperty) (times []time.Time, err error) {
+	location := time.Local
+	if tzid, ok := property.params["TZID"]; ok {
+		if location, err = time.LoadLocation(tzid); err != nil {
+			log.Warnf("unknown time zone %s, using local time instead", tzid)
+			location = time.Local
+		}
+	}
+	for _, value := range strings.Split(property.value, ",") {
+		var t time.Time
+		switch {
+		case strings.HasSuffix(value, "Z"):
+			t, err = time.Parse(icsTimeFormat, value)
+		case len(value) == len(icsDateFormat):
+			t, err = time.ParseInLocation(icsDateFormat, value, time.Local)
+		default:
+			t, err = time.ParseInLocation(icsLocalTimeFormat, value, location)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse time %s: %w", value, err)
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// icsDuration is a DURATION value, split into its nominal days and exact hours, minutes
+// and seconds
+type icsDuration struct {
+	days  int
+	exact time.Duration
+}
+
+// parseICSDuration reads a duration such as P1W, P1D or PT1H30M
+func parseICSDuration(value string) (d icsDuration, err error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	if rest == value || rest == "" {
+		return d, fmt.Errorf("could not parse duration %s", value)
+	}
+	inTime := false
+	number := ""
+	parts := 0
+	for _, c := range rest {
+		switch {
+		case c >= '0' && c <= '9':
+			number += string(c)
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return d, fmt.Errorf("could not parse duration %s", value)
+		}
+		number = ""
+		parts++
+		switch {
+		case c == 'W' && !inTime:
+			d.days += 7 * n
+		case c == 'D' && !inTime:
+			d.days += n
+		case c == 'H' && inTime:
+			d.exact += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			d.exact += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			d.exact += time.Duration(n) * time.Second
+		default:
+			return d, fmt.Errorf("could not parse duration %s", value)
+		}
+	}
+	if number != "" || parts == 0 {
+		return d, fmt.Errorf("could not parse duration %s", value)
+	}
+	return d, nil
+}
+
+// parseRRule reads the parts of an RRULE we support, refusing any rule using other parts,
+// rather than expanding it wrongly
+func parseRRule(value string, location *time.Location) (*types.Recurrence, error) {
+	r := &types.Recurrence{}
+	for _, part := range strings.Split(value, ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		key, value := keyValue[0], keyValue[len(keyValue)-1]
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Frequency = strings.ToLower(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "UNTIL":
+			var until []time.Time
+			until, err = parseICSTimes(icsProperty{value: value})
+			if err == nil {
+				r.Until = until[0]
+				if len(value) == len(icsDateFormat) {
+					// the whole of the last day counts
+					r.Until = time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(), 23, 59, 59, 0, location)
+				}
+			}
+		case "BYDAY":
+			r.ByDay = strings.Split(value, ",")
+		case "WKST":
+			if !strings.EqualFold(value, "MO") {
+				return nil, fmt.Errorf("unsupported week start %s", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse recurrence rule part %s: %w", part, err)
+		}
+	}
+	return r, nil
+}
+
+// icsUnescape undoes the escaping of text values
+func icsUnescape(text string) string {
+	var unescaped bytes.Buffer
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			if text[i] == 'n' || text[i] == 'N' {
+				unescaped.WriteByte('\n')
+				continue
+			}
+		}
+		unescaped.WriteByte(text[i])
+	}
+	return unescaped.String()
+}
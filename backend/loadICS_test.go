@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCalendar = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example//Calendar//EN
+BEGIN:VEVENT
+UID:standup
+DTSTART;TZID=America/New_York:20300107T090000
+DURATION:PT30M
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4
+EXDATE;TZID=America/New_York:20300109T090000
+SUMMARY:Stand\, up
+BEGIN:VALARM
+TRIGGER:-PT5M
+SUMMARY:reminder
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+RECURRENCE-ID;TZID=America/New_York:20300114T090000
+DTSTART;TZID=America/New_York:20300114T110000
+DTEND;TZID=America/New_York:20300114T113000
+SUMMARY:Stand\, up (moved)
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday
+DTSTART;VALUE=DATE:20300110
+SUMMARY:Holiday with a summary long enough to be
+  folded
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled
+DTSTART:20300108T090000Z
+DTEND:20300108T100000Z
+STATUS:CANCELLED
+END:VEVENT
+BEGIN:VEVENT
+UID:free
+DTSTART:20300108T090000Z
+DTEND:20300108T100000Z
+TRANSP:TRANSPARENT
+END:VEVENT
+BEGIN:VEVENT
+UID:unsupported
+DTSTART:20300108T090000Z
+DTEND:20300108T100000Z
+RRULE:FREQ=MONTHLY;BYDAY=1MO
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseICS(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	events, err := parseICS(strings.NewReader(strings.ReplaceAll(testCalendar, "\n", "\r\n")), "work.ics")
+	assert.NoError(t, err)
+	// cancelled, free and unsupported events are left out
+	assert.Len(t, events, 3)
+	for _, event := range events {
+		assert.True(t, event.imported)
+		assert.Equal(t, "work.ics", event.Source)
+	}
+
+	standup := events[0]
+	assert.Equal(t, "Stand, up", standup.Name)
+	assert.True(t, standup.StartTime.Equal(time.Date(2030, 1, 7, 14, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 30*time.Minute, standup.EndTime.Sub(standup.StartTime))
+	// the excluded and the moved occurrences are skipped, but still count towards COUNT
+	rec, problems := newRecurrence(*standup.Recurrence)
+	assert.Empty(t, problems)
+	occurrences := rec.occurrences(standup.StartTime, time.Date(2031, 1, 1, 0, 0, 0, 0, newYork))
+	assert.Equal(t, []time.Time{
+		time.Date(2030, 1, 7, 9, 0, 0, 0, newYork),
+		time.Date(2030, 1, 16, 9, 0, 0, 0, newYork),
+	}, occurrences)
+
+	moved := events[1]
+	assert.Nil(t, moved.Recurrence)
+	assert.True(t, moved.StartTime.Equal(time.Date(2030, 1, 14, 16, 0, 0, 0, time.UTC)))
+
+	holiday := events[2]
+	assert.Equal(t, "Holiday with a summary long enough to be folded", holiday.Name)
+	assert.Equal(t, time.Date(2030, 1, 10, 0, 0, 0, 0, time.Local), holiday.StartTime)
+	assert.Equal(t, time.Date(2030, 1, 11, 0, 0, 0, 0, time.Local), holiday.EndTime)
+
+	// calendars exported from timetables are not read back in
+	exported := "BEGIN:VCALENDAR\r\nPRODID:" + icsProdID + "\r\nBEGIN:VEVENT\r\nDTSTART:20300108T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	events, err = parseICS(strings.NewReader(exported), "plan.ics")
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	_, err = parseICS(strings.NewReader("BEGIN:VCALENDAR\r\nnot a property\r\n"), "broken.ics")
+	assert.Error(t, err)
+}
+
+func TestParseICSDuration(t *testing.T) {
+	d, err := parseICSDuration("P1W2DT1H30M")
+	assert.NoError(t, err)
+	assert.Equal(t, icsDuration{days: 9, exact: 90 * time.Minute}, d)
+	for _, invalid := range []string{"", "1H", "PT", "P1H", "PT1D", "P1"} {
+		_, err = parseICSDuration(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestGenerateWithCalendar(t *testing.T) {
+	dir := t.TempDir()
+	input := "[[deadlines]]\nname = \"essay\"\nminutesRemaining = 50\ndeadline = 2030-01-08T10:30:00Z\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(input), 0644))
+	calendar := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20300101T090000Z\nDTEND:20300101T100000Z\nRRULE:FREQ=DAILY\nSUMMARY:meetings\nEND:VEVENT\nEND:VCALENDAR\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "work.ics"), []byte(calendar), 0644))
+
+	in, err := Load(dir)
+	assert.NoError(t, err)
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	timetable, err := Generate(context.Background(), in, Options{Now: now, Slots: 4, Strategy: Spread})
+	assert.NoError(t, err)
+	// the daily meeting, started in the past, takes up both mornings
+	for _, i := range []int{0, 1, 48, 49} {
+		assert.Equal(t, EventSlot, timetable.Slots[i].Kind, i)
+		assert.Equal(t, "meetings", timetable.Slots[i].Event.Name)
+	}
+	assert.Equal(t, DeadlineSlot, timetable.Slots[2].Kind)
+
+	// on the day of the deadline, the meeting leaves too little time for it
+	_, err = Generate(context.Background(), in, Options{Now: now.Add(24*time.Hour + 30*time.Minute), Strategy: Spread})
+	var infeasibleErr *InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+
+	_, err = Load(dir, filepath.Join(dir, "missing.ics"))
+	assert.Error(t, err)
+}
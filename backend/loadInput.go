@@ -1,12 +1,13 @@
 package backend
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
-	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -14,41 +15,74 @@ import (
 	"github.com/pelletier/go-toml/v2"
 )
 
-type event struct {
+// Event is an event, which blocks out the slots it covers each time it occurs
+type Event struct {
 	types.Event
+	// Source is the .at.toml or .ics the event was read from
+	Source string `json:"source,omitempty" toml:"-"`
+	// imported events come from a calendar, so they may overlap or have passed
+	imported bool
 }
 
-type deadline struct {
+// Deadline is some work to be spread over the free slots before it is due
+type Deadline struct {
 	types.Deadline
-	slotsRemaining   int       `json:"-"`
-	slotsAvailable   int       `json:"-"`
+	// Source is the .at.toml the deadline was read from
+	Source string `json:"source,omitempty" toml:"-"`
 }
 
-type periodic struct {
+// Periodic is something that may happen in any slot, at some rate per day, or a habit kept up
+// in free slots a number of times a day or week
+type Periodic struct {
 	types.Periodic
+	// Source is the .at.toml the periodic was read from
+	Source string `json:"source,omitempty" toml:"-"`
 }
 
-type inputData struct {
-	Events    []event    `json:"events" toml:"events"`
-	Deadlines []deadline `json:"deadlines" toml:"deadlines"`
-	Periodics []periodic `json:"periodic" toml:"periodics"`
-	slots     int        `json:"-"`
+// Input is everything read from the .at.toml and .ics files in a tree
+type Input struct {
+	Events    []Event    `json:"events" toml:"events"`
+	Deadlines []Deadline `json:"deadlines" toml:"deadlines"`
+	Periodics []Periodic `json:"periodic" toml:"periodics"`
+	// Settings and Availability are only read from the .at.toml at the top of the tree
+	Settings     types.Settings     `json:"settings" toml:"settings"`
+	Availability types.Availability `json:"availability" toml:"availability"`
+	// Done names the deadlines logged as done in the work log at the top of the tree, which
+	// others can still depend on
+	Done []string `json:"done,omitempty" toml:"-"`
 }
 
-// GetInput is the function will read the JSON file into the structs
-func GetInput(dirPtr *string, noOfSlots int) (data inputData) {
-	tomlPaths, err := getTomls(dirPtr)
+// Load reads every .at.toml file under dir into an Input, along with the events in every .ics
+// file under dir and in icsPaths, sorted and checked
+// if the data are invalid, the error is a *ValidationError listing every problem
+func Load(dir string, icsPaths ...string) (*Input, error) {
+	tomlPaths, err := getTomls(&dir)
 	if err != nil {
-		log.Fatalf("could not find .at.toml config files: %s", err)
+		return nil, fmt.Errorf("could not find .at.toml config files: %w", err)
 	}
-	data, err = tomlsToInputData(tomlPaths)
+	data, err := tomlsToInputData(tomlPaths)
+	if err != nil && !errors.Is(err, ErrNoData) {
+		return nil, err
+	}
+	calendarEvents, err := getCalendarEvents(dir, icsPaths)
 	if err != nil {
-		log.Fatalf("could not find any event, deadline, or periodic data: %s", err)
+		return nil, err
+	}
+	data.Events = append(data.Events, calendarEvents...)
+	if len(data.Events) == 0 && len(data.Deadlines) == 0 {
+		return nil, fmt.Errorf("could not find any event, deadline, or periodic data: %w", ErrNoData)
+	}
+	if err := getSettings(dir, data); err != nil {
+		return nil, err
+	}
+	if err := getDone(dir, data); err != nil {
+		return nil, err
 	}
 	sortData(data)
-	checkData(data)
-	data.slots = noOfSlots
-	return data
+	if err := validationError(checkData(data)); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 // getTomls finds all files named .at.toml in the file hierarchy, where filePtr is considered
@@ -66,141 +100,322 @@ func getTomls(filePtr *string) ([]string, error) {
 		return nil, fmt.Errorf("error walking directory: %w", err)
 	}
 	if len(tomls) == 0 {
-		return nil, fmt.Errorf("could not find any files called .at.toml")
+		return nil, ErrNoTomls
 	}
 	return tomls, nil
 }
 
-// tomlsToInputData takes a list of toml files and collects them into inputData ([]events and []deadlines)
-func tomlsToInputData(tomlPaths []string) (inputData, error) {
-	var events []event
-	var deadlines []deadline
-	var periodics []periodic
+// tomlsToInputData takes a list of toml files and collects them into an Input ([]Event and []Deadline),
+// returning what it collected along with ErrNoData if there are no events or deadlines
+func tomlsToInputData(tomlPaths []string) (*Input, error) {
+	var events []Event
+	var deadlines []Deadline
+	var periodics []Periodic
 	for _, tomlPath := range tomlPaths {
 		dataRaw, err := os.ReadFile(tomlPath)
 		if err != nil {
 			log.Warnf("could not open toml file %s: %s", tomlPath, err)
 			continue
 		}
-		var localisedInputData inputData
+		var localisedInputData Input
 		err = toml.Unmarshal(dataRaw, &localisedInputData)
 		if err != nil {
 			log.Warnf("could not process toml file %s as valid input data: %s", tomlPath, err)
 			continue
 		}
-		events = append(events, localisedInputData.Events...)
-		deadlines = append(deadlines, localisedInputData.Deadlines...)
-		periodics = append(periodics, localisedInputData.Periodics...)
+		for _, event := range localisedInputData.Events {
+			event.Source = tomlPath
+			events = append(events, event)
+		}
+		for _, deadline := range localisedInputData.Deadlines {
+			deadline.Source = tomlPath
+			deadlines = append(deadlines, deadline)
+		}
+		for _, periodic := range localisedInputData.Periodics {
+			periodic.Source = tomlPath
+			periodics = append(periodics, periodic)
+		}
 	}
+	data := &Input{Events: events, Deadlines: deadlines, Periodics: periodics}
 	if len(events) == 0 && len(deadlines) == 0 {
-		return inputData{}, fmt.Errorf("could not find any events or deadlines")
+		return data, ErrNoData
 	}
-	return inputData{Events: events, Deadlines: deadlines, Periodics: periodics}, nil
+	return data, nil
 }
 
 // sortData sorts events and deadlines by start date and upcoming date, respectively
-// it also does rounding
-func sortData(data inputData) {
+func sortData(data *Input) {
 	sortEvents(data.Events)
 	sortDeadlines(data.Deadlines)
 }
 
-// checkData checks the validity of the data
-func checkData(data inputData) {
-	checkEvents(data.Events)
-	checkDeadlines(data.Deadlines)
-	checkPeriodics(data.Periodics)
+// checkData checks the validity of the data, returning every problem found
+func checkData(data *Input) (problems []error) {
+	problems = append(problems, checkEvents(data.Events)...)
+	problems = append(problems, checkOverlaps(data.Events)...)
+	problems = append(problems, checkDeadlines(data.Deadlines)...)
+	problems = append(problems, checkDependencies(data.Deadlines, data.Done)...)
+	problems = append(problems, checkPeriodics(data.Periodics)...)
+	return problems
 }
 
 // sortEvents to sort by start time
-func sortEvents(events []event) {
-	for i, event := range events {
-		events[i].StartTime = roundDown(event.StartTime)
-		events[i].EndTime = roundUp(event.EndTime)
-	}
+func sortEvents(events []Event) {
 	sort.Slice(events, func(p, q int) bool {
 		return events[p].StartTime.Before(events[q].StartTime)
 	})
 }
 
-// sortDeadlines to sort by deadline
-func sortDeadlines(deadlines []deadline) {
-	for i, deadline := range deadlines {
-		deadlines[i].MinutesRemaining = math.Ceil(deadline.MinutesRemaining/25) * 25
-		deadlines[i].DeadlineTime = roundDown(deadline.DeadlineTime)
-	}
-	sort.Slice(deadlines, func(p, q int) bool {
+// sortDeadlines to sort by deadline, with the highest priority first among those due together
+func sortDeadlines(deadlines []Deadline) {
+	sort.SliceStable(deadlines, func(p, q int) bool {
+		if deadlines[p].DeadlineTime.Equal(deadlines[q].DeadlineTime) {
+			return deadlines[p].Priority > deadlines[q].Priority
+		}
 		return deadlines[p].DeadlineTime.Before(deadlines[q].DeadlineTime)
 	})
 }
 
-// checkEvents will ensure events all start in the future, have an end date after start date
-// and do not intersect
-func checkEvents(events []event) {
-	// if events are empty, it is trivial that they are compliant
-	if len(events) == 0 {
-		return
+// checkEvents will ensure events are named, have an end date after start date and, if they
+// recur, a valid recurrence
+func checkEvents(events []Event) (problems []error) {
+	for _, event := range events {
+		// check that the event has a name
+		if event.Name == "" {
+			problems = append(problems, ErrUnnamedEvent)
+		}
+		// check every event's start time is before the end time
+		if event.EndTime.Before(event.StartTime) {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrEventEndsBeforeStart, event.Name))
+		}
+		if event.Recurrence != nil {
+			_, recurrenceProblems := newRecurrence(*event.Recurrence)
+			for _, problem := range recurrenceProblems {
+				problems = append(problems, fmt.Errorf("%w (%s)", problem, event.Name))
+			}
+		}
 	}
-	// data are sorted, so check first event
-	if events[0].EndTime.Before(currentTime) {
-		log.Fatalf("found an event %s that has already passed", events[0].Name)
+	return problems
+}
+
+// checkOverlaps will ensure sorted events do not intersect, leaving out events imported from
+// calendars, which may, and recurring events, whose occurrences are checked once expanded
+func checkOverlaps(events []Event) (problems []error) {
+	// check each event does not start before the latest-ending of its predecessors ends
+	latest := -1
+	for i, event := range events {
+		if event.imported || event.Recurrence != nil {
+			continue
+		}
+		if latest >= 0 && event.StartTime.Before(events[latest].EndTime) {
+			problems = append(problems, fmt.Errorf("%w: %s starts before %s ends", ErrOverlappingEvents, event.Name, events[latest].Name))
+		}
+		if latest < 0 || event.EndTime.After(events[latest].EndTime) {
+			latest = i
+		}
 	}
+	return problems
+}
 
-	// check that the event has a name
-	for _, event := range events {
-		if event.Name == "" {
-			log.Fatalf("found an event with no name")
+// CheckEvent checks that newEvent could be added to the tree rooted at dir without
+// breaking any of the rules checkEvents enforces, overlapping another event, or having
+// passed by the time opts plans from
+func CheckEvent(dir string, newEvent types.Event, opts Options) error {
+	data, err := getExistingInput(dir)
+	if err != nil {
+		return err
+	}
+	data.Events = append(data.Events, Event{Event: newEvent})
+	sortEvents(data.Events)
+	problems := checkEvents(data.Events)
+	// only occurrences around the new event can overlap it
+	if newEvent.Recurrence == nil {
+		problems = append(problems, checkOverlaps(expandEvents(data.Events, newEvent.StartTime, newEvent.EndTime))...)
+	} else {
+		problems = append(problems, checkOverlaps(data.Events)...)
+	}
+	problems = append(problems, checkNotPassed(data, opts.now())...)
+	return validationError(problems)
+}
+
+// getExistingInput reads whatever input exists under dir, treating a tree with
+// no .at.toml files or no events and deadlines as empty
+func getExistingInput(dir string) (*Input, error) {
+	tomlPaths, err := getTomls(&dir)
+	if errors.Is(err, ErrNoTomls) {
+		data := &Input{}
+		return data, getDone(dir, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := tomlsToInputData(tomlPaths)
+	if err != nil && !errors.Is(err, ErrNoData) {
+		return nil, err
+	}
+	calendarEvents, err := getCalendarEvents(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	data.Events = append(data.Events, calendarEvents...)
+	if err := getSettings(dir, data); err != nil {
+		return nil, err
+	}
+	return data, getDone(dir, data)
+}
+
+// getSettings reads the settings and availability tables from the .at.toml at the top of the
+// tree into data, if there is one
+func getSettings(dir string, data *Input) error {
+	var topLevel struct {
+		Settings     types.Settings     `toml:"settings"`
+		Availability types.Availability `toml:"availability"`
+	}
+	dataRaw, err := os.ReadFile(filepath.Join(dir, ".at.toml"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read settings: %w", err)
+	}
+	if err := toml.Unmarshal(dataRaw, &topLevel); err != nil {
+		return fmt.Errorf("could not read settings: %w", err)
+	}
+	data.Settings, data.Availability = topLevel.Settings, topLevel.Availability
+	return nil
+}
+
+// getDone reads the names of the deadlines logged as done from the .at.log.toml at the top of
+// the tree, if there is one
+func getDone(dir string, data *Input) error {
+	var workLog struct {
+		Log []types.LogEntry `toml:"log"`
+	}
+	dataRaw, err := os.ReadFile(filepath.Join(dir, ".at.log.toml"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read the work log: %w", err)
+	}
+	if err := toml.Unmarshal(dataRaw, &workLog); err != nil {
+		return fmt.Errorf("could not read the work log: %w", err)
+	}
+	for _, entry := range workLog.Log {
+		if entry.Done {
+			data.Done = append(data.Done, entry.Deadline)
 		}
 	}
+	return nil
+}
 
-	// check every event's start time is before the end time
-	for _, event := range events {
-		if event.EndTime.Before(event.StartTime) {
-			log.Fatalf("found an event %s with end time before start time", event.Name)
+// checkDeadlines will ensure deadlines are named and have work remaining
+func checkDeadlines(deadlines []Deadline) (problems []error) {
+	for _, deadline := range deadlines {
+		if deadline.Name == "" {
+			problems = append(problems, ErrUnnamedDeadline)
+		}
+		if deadline.MinutesRemaining <= 0 {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrNoMinutesRemaining, deadline.Name))
+		}
+		if deadline.StartTime != nil && !deadline.StartTime.Before(deadline.DeadlineTime) {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrStartsAfterDeadline, deadline.Name))
+		}
+		if deadline.MinBlock < 0 || deadline.MaxBlock < 0 || (deadline.MaxBlock > 0 && deadline.MinBlock > deadline.MaxBlock) {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrInvalidBlocks, deadline.Name))
 		}
 	}
+	return problems
+}
 
-	// now check for each event that it's successor does not start before it
-	for i := range events {
-		if i >= len(events)-1 {
-			break
+// checkNotPassed will ensure no event or deadline is over by the time the timetable starts,
+// other than events imported from calendars, which are full of the past; a recurring event
+// has only passed once its last occurrence has
+func checkNotPassed(data *Input, start time.Time) (problems []error) {
+	for _, event := range data.Events {
+		if !event.imported && event.hasPassed(start) {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrEventInPast, event.Name))
 		}
-		if events[i+1].StartTime.Before(events[i].EndTime) {
-			log.Fatalf("found an event %s with start time before event %s ends", events[i+1].Name, events[i].Name)
+	}
+	for _, deadline := range data.Deadlines {
+		if deadline.DeadlineTime.Before(start) {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrDeadlineInPast, deadline.Name))
 		}
 	}
+	return problems
 }
 
-// checkDeadlines will ensure deadlines are in the future
-func checkDeadlines(deadlines []deadline) {
-	// since we assume data are sorted, just check the first deadline
-	if len(deadlines) > 0 && deadlines[0].DeadlineTime.Before(currentTime) {
-		log.Fatalf("found a deadline %s that has already passed", deadlines[0].Name)
+// CheckDeadline checks that newDeadline is valid and that, once it is added to the tree
+// rooted at dir, there is still time for everything; if there is not, the error
+// is an *InfeasibleError
+func CheckDeadline(dir string, newDeadline types.Deadline, opts Options) error {
+	data, err := getExistingInput(dir)
+	if err != nil {
+		return err
+	}
+	data.Deadlines = append(data.Deadlines, Deadline{Deadline: newDeadline})
+	sortData(data)
+	// plan from the same time the checks were made at
+	opts.Now = opts.now()
+	problems := checkData(data)
+	problems = append(problems, checkNotPassed(data, opts.Now)...)
+	if err := validationError(problems); err != nil {
+		return err
+	}
+	p, err := newPlan(data, opts)
+	if err != nil {
+		return err
 	}
+	return p.feasibilityError()
 }
 
-// checkPeriodics will ensure periodics have a positive probability
-func checkPeriodics(periodics []periodic) {
+// FindDeadline finds the deadline called name in the .at.toml files under dir, with its Source
+// the file it is set in, returning ErrDeadlineNotFound if there is none and an error if more
+// than one deadline has the name
+func FindDeadline(dir string, name string) (*Deadline, error) {
+	tomlPaths, err := getTomls(&dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not find .at.toml config files: %w", err)
+	}
+	data, err := tomlsToInputData(tomlPaths)
+	if err != nil && !errors.Is(err, ErrNoData) {
+		return nil, err
+	}
+	var found []Deadline
+	var sources []string
+	for _, deadline := range data.Deadlines {
+		if deadline.Name == name {
+			found = append(found, deadline)
+			sources = append(sources, deadline.Source)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s", ErrDeadlineNotFound, name)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("found %d deadlines called %s, in %s", len(found), name, strings.Join(sources, ", "))
+	}
+}
+
+// checkPeriodics will ensure periodics have a positive probability, or are valid habits
+func checkPeriodics(periodics []Periodic) (problems []error) {
 	for _, periodic := range periodics {
 		// check there is a name
 		if periodic.Name == "" {
-			log.Fatal("found a periodic with no name")
+			problems = append(problems, ErrUnnamedPeriodic)
+		}
+		if periodic.habit() {
+			problems = append(problems, checkHabit(periodic)...)
+			continue
 		}
 		if periodic.Probability <= 0 {
-			log.Fatalf("found a periodic %s with nonpositive probability", periodic.Name)
+			problems = append(problems, fmt.Errorf("%w: %s", ErrNonpositiveProbability, periodic.Name))
+		}
+		if periodic.DurationMinutes < 0 || periodic.PerDay != 0 || periodic.PerWeek != 0 || len(periodic.Windows) > 0 || periodic.MinSpacingMinutes != 0 {
+			problems = append(problems, fmt.Errorf("%w: %s needs a positive durationMinutes to be a habit", ErrInvalidHabit, periodic.Name))
 		}
 	}
-}
-// roundUp rounds a time up to its nearest 30-minute point
-func roundUp(unrounded time.Time) (rounded time.Time) {
-	rounded = unrounded.Truncate(30 * time.Minute)
-	if unrounded == rounded {
-		return rounded
-	}
-	return rounded.Add(30 * time.Minute)
-}
-
-// roundDown rounds a time down to its nearest 30-minute point
-func roundDown(unrounded time.Time) time.Time {
-	return unrounded.Truncate(30 * time.Minute)
+	return problems
 }
@@ -2,6 +2,9 @@ package backend
 
 import(
 	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,4 +24,38 @@ func TestTomlsToInputData(t *testing.T) {
 	correctTomlPaths := []string{"testdata/.at.toml", "testdata/foldera/.at.toml", "testdata/folderb/folderc/.at.toml"}
 	_, err := tomlsToInputData(correctTomlPaths)
 	assert.Error(t, err)
-}
\ No newline at end of file
+}
+
+func TestCheckData(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	hour := now.Add(time.Hour)
+	data := &Input{
+		Events: []Event{
+			{Event: types.Event{Name: "past", StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Hour)}},
+			{Event: types.Event{Name: "long", StartTime: hour, EndTime: hour.Add(3 * time.Hour)}},
+			{Event: types.Event{Name: "inside", StartTime: hour.Add(time.Hour), EndTime: hour.Add(2 * time.Hour)}},
+			{Event: types.Event{Name: "", StartTime: hour.Add(5 * time.Hour), EndTime: hour.Add(6 * time.Hour)}},
+		},
+		Deadlines: []Deadline{{Deadline: types.Deadline{Name: "nothing to do", DeadlineTime: hour}}},
+	}
+	sortData(data)
+	err := validationError(checkData(data))
+
+	// every problem is reported, not just the first
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 3)
+	assert.ErrorIs(t, err, ErrOverlappingEvents)
+	assert.ErrorIs(t, err, ErrUnnamedEvent)
+	assert.ErrorIs(t, err, ErrNoMinutesRemaining)
+
+	assert.Empty(t, checkData(&Input{}))
+
+	// whether things have passed depends on when the timetable starts
+	err = validationError(checkNotPassed(data, now))
+	assert.ErrorIs(t, err, ErrEventInPast)
+	assert.NotErrorIs(t, err, ErrDeadlineInPast)
+	err = validationError(checkNotPassed(data, hour.Add(time.Minute)))
+	assert.ErrorIs(t, err, ErrDeadlineInPast)
+	assert.Empty(t, checkNotPassed(data, now.Add(-3*time.Hour)))
+}
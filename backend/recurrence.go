@@ -0,0 +1,209 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+)
+
+// frequencies a recurrence can repeat at
+const (
+	daily   = "daily"
+	weekly  = "weekly"
+	monthly = "monthly"
+	yearly  = "yearly"
+)
+
+// weekdays are the days of the week as an RRULE names them
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// recurrence is a types.Recurrence read into the form occurrences are worked out from
+type recurrence struct {
+	frequency  string
+	interval   int
+	byDay      []time.Weekday
+	until      time.Time
+	count      int
+	exceptions []time.Time
+}
+
+// newRecurrence reads a types.Recurrence, returning every problem with it
+func newRecurrence(r types.Recurrence) (rec recurrence, problems []error) {
+	rec = recurrence{frequency: strings.ToLower(r.Frequency), interval: r.Interval, until: r.Until, count: r.Count, exceptions: r.Exceptions}
+	switch rec.frequency {
+	case daily, weekly, monthly, yearly:
+	default:
+		problems = append(problems, fmt.Errorf("%w: unknown frequency %q", ErrInvalidRecurrence, r.Frequency))
+	}
+	if rec.interval < 0 || rec.count < 0 {
+		problems = append(problems, fmt.Errorf("%w: interval and count cannot be negative", ErrInvalidRecurrence))
+	}
+	if rec.count > 0 && !rec.until.IsZero() {
+		problems = append(problems, fmt.Errorf("%w: only one of until and count can be set", ErrInvalidRecurrence))
+	}
+	for _, day := range r.ByDay {
+		weekday, ok := weekdays[strings.ToUpper(day)]
+		if !ok {
+			problems = append(problems, fmt.Errorf("%w: unknown day %q", ErrInvalidRecurrence, day))
+		}
+		rec.byDay = append(rec.byDay, weekday)
+	}
+	if len(rec.byDay) > 0 && rec.frequency != daily && rec.frequency != weekly {
+		problems = append(problems, fmt.Errorf("%w: days can only be given for daily or weekly recurrences", ErrInvalidRecurrence))
+	}
+	return rec, problems
+}
+
+// bounded says whether the recurrence ends
+func (r recurrence) bounded() bool {
+	return r.count > 0 || !r.until.IsZero()
+}
+
+// maxOccurrenceSteps stops an unbounded recurrence that never reaches the horizon
+const maxOccurrenceSteps = 100000
+
+// occurrences lists the start times of the occurrences of a recurrence starting at start,
+// up to but not including to
+func (r recurrence) occurrences(start time.Time, to time.Time) (starts []time.Time) {
+	interval := r.interval
+	if interval == 0 {
+		interval = 1
+	}
+	counted := 0
+	for step := 0; step < maxOccurrenceSteps; step++ {
+		candidates := r.candidates(start, step*interval)
+		if len(candidates) == 0 {
+			continue
+		}
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				continue
+			}
+			if !candidate.Before(to) || (!r.until.IsZero() && candidate.After(r.until)) || (r.count > 0 && counted >= r.count) {
+				return starts
+			}
+			counted++
+			if !r.isException(candidate) {
+				starts = append(starts, candidate)
+			}
+		}
+	}
+	return starts
+}
+
+// candidates lists the possible occurrences offset periods after the one starting at start,
+// which for monthly and yearly recurrences is none if the date does not exist
+func (r recurrence) candidates(start time.Time, offset int) []time.Time {
+	switch r.frequency {
+	case weekly:
+		days := r.byDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		// weeks start on a Monday
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*offset)
+		candidates := make([]time.Time, 0, len(days))
+		for _, day := range days {
+			candidates = append(candidates, monday.AddDate(0, 0, (int(day)+6)%7))
+		}
+		sort.Slice(candidates, func(p, q int) bool {
+			return candidates[p].Before(candidates[q])
+		})
+		return candidates
+	case monthly:
+		candidate := start.AddDate(0, offset, 0)
+		if candidate.Day() != start.Day() {
+			return nil
+		}
+		return []time.Time{candidate}
+	case yearly:
+		candidate := start.AddDate(offset, 0, 0)
+		if candidate.Day() != start.Day() {
+			return nil
+		}
+		return []time.Time{candidate}
+	default:
+		candidate := start.AddDate(0, 0, offset)
+		if len(r.byDay) > 0 && !hasWeekday(r.byDay, candidate.Weekday()) {
+			return nil
+		}
+		return []time.Time{candidate}
+	}
+}
+
+func hasWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (r recurrence) isException(candidate time.Time) bool {
+	for _, exception := range r.exceptions {
+		if exception.Equal(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandEvents lists the one-off events, along with the occurrences of recurring events, that
+// end after from and start before to, sorted by start time
+// events with invalid recurrences are left out, as checkEvents reports them
+func expandEvents(events []Event, from time.Time, to time.Time) []Event {
+	var expanded []Event
+	for _, event := range events {
+		if event.Recurrence == nil {
+			if event.EndTime.After(from) {
+				expanded = append(expanded, event)
+			}
+			continue
+		}
+		rec, problems := newRecurrence(*event.Recurrence)
+		if len(problems) > 0 {
+			continue
+		}
+		length := event.EndTime.Sub(event.StartTime)
+		for _, start := range rec.occurrences(event.StartTime, to) {
+			if !start.Add(length).After(from) {
+				continue
+			}
+			occurrence := event
+			occurrence.StartTime = start
+			occurrence.EndTime = start.Add(length)
+			occurrence.Recurrence = nil
+			expanded = append(expanded, occurrence)
+		}
+	}
+	sortEvents(expanded)
+	return expanded
+}
+
+// endOfTime is later than any occurrence, for expanding recurrences that end
+var endOfTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// hasPassed says whether every occurrence of an event ends before now, which a recurrence
+// without an end never does
+func (e Event) hasPassed(now time.Time) bool {
+	if e.Recurrence == nil {
+		return e.EndTime.Before(now)
+	}
+	rec, problems := newRecurrence(*e.Recurrence)
+	if len(problems) > 0 || !rec.bounded() {
+		return false
+	}
+	return len(expandEvents([]Event{e}, now, endOfTime)) == 0
+}
@@ -0,0 +1,107 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOccurrences(t *testing.T) {
+	start := time.Date(2030, 1, 31, 9, 0, 0, 0, time.UTC)
+	to := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// months without a 31st are skipped
+	byMonth := recurrence{frequency: monthly}
+	assert.Equal(t, []time.Time{start, start.AddDate(0, 2, 0), start.AddDate(0, 4, 0)}, byMonth.occurrences(start, to))
+
+	// until includes an occurrence starting at that time
+	everyOtherDay := recurrence{frequency: daily, interval: 2, until: start.AddDate(0, 0, 4)}
+	assert.Equal(t, []time.Time{start, start.AddDate(0, 0, 2), start.AddDate(0, 0, 4)}, everyOtherDay.occurrences(start, to))
+
+	// a daily recurrence limited to weekdays skips the weekend
+	weekdays := recurrence{frequency: daily, byDay: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}}
+	assert.Len(t, weekdays.occurrences(start, start.AddDate(0, 0, 7)), 5)
+}
+
+func TestExpandEvents(t *testing.T) {
+	from := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Event: types.Event{Name: "weekly", StartTime: from.AddDate(0, 0, -14), EndTime: from.AddDate(0, 0, -14).Add(time.Hour), Recurrence: &types.Recurrence{Frequency: "weekly"}}},
+		{Event: types.Event{Name: "once", StartTime: from.Add(2 * time.Hour), EndTime: from.Add(3 * time.Hour)}},
+		{Event: types.Event{Name: "passed", StartTime: from.Add(-2 * time.Hour), EndTime: from.Add(-time.Hour)}},
+	}
+	expanded := expandEvents(events, from.Add(30*time.Minute), from.AddDate(0, 0, 8))
+	// the occurrence under way at from is kept, and the passed event left out
+	assert.Len(t, expanded, 3)
+	assert.Equal(t, from, expanded[0].StartTime)
+	assert.Equal(t, "once", expanded[1].Name)
+	assert.Equal(t, from.AddDate(0, 0, 7), expanded[2].StartTime)
+	assert.Nil(t, expanded[2].Recurrence)
+}
+
+func TestNewRecurrence(t *testing.T) {
+	rec, problems := newRecurrence(types.Recurrence{Frequency: "Weekly", ByDay: []string{"mo", "FR"}})
+	assert.Empty(t, problems)
+	assert.Equal(t, weekly, rec.frequency)
+	assert.Equal(t, []time.Weekday{time.Monday, time.Friday}, rec.byDay)
+
+	_, problems = newRecurrence(types.Recurrence{Frequency: "hourly", ByDay: []string{"1MO"}, Count: 2, Until: time.Now()})
+	assert.Len(t, problems, 4)
+	for _, problem := range problems {
+		assert.ErrorIs(t, problem, ErrInvalidRecurrence)
+	}
+	_, problems = newRecurrence(types.Recurrence{Frequency: monthly, ByDay: []string{"MO"}})
+	assert.Len(t, problems, 1)
+}
+
+func TestRecurringEvents(t *testing.T) {
+	dir := t.TempDir()
+	input := `[[events]]
+name = "seminar"
+startTime = 2030-01-01T10:00:00Z
+endTime = 2030-01-01T11:00:00Z
+[events.recurrence]
+frequency = "weekly"
+byDay = ["TU", "TH"]
+until = 2030-01-10T10:00:00Z
+exceptions = [2030-01-08T10:00:00Z]
+
+[[deadlines]]
+name = "essay"
+minutesRemaining = 25
+deadline = 2030-01-10T12:00:00Z
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(input), 0644))
+	in, err := Load(dir)
+	assert.NoError(t, err)
+
+	// the first occurrences have passed, but not the event
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: Spread})
+	assert.NoError(t, err)
+	var seminars []time.Time
+	for _, slot := range timetable.Slots {
+		if slot.Kind == EventSlot {
+			seminars = append(seminars, slot.Start)
+		}
+	}
+	assert.Equal(t, []time.Time{time.Date(2030, 1, 10, 10, 0, 0, 0, time.UTC), time.Date(2030, 1, 10, 10, 30, 0, 0, time.UTC)}, seminars)
+
+	// once the last occurrence is over, the event has passed
+	assert.ErrorIs(t, validationError(checkNotPassed(in, time.Date(2030, 1, 10, 11, 30, 0, 0, time.UTC))), ErrEventInPast)
+
+	// occurrences are checked for overlaps
+	err = CheckEvent(dir, types.Event{Name: "lunch", StartTime: time.Date(2030, 1, 10, 10, 30, 0, 0, time.UTC), EndTime: time.Date(2030, 1, 10, 11, 30, 0, 0, time.UTC)}, Options{Now: now})
+	assert.ErrorIs(t, err, ErrOverlappingEvents)
+	err = CheckEvent(dir, types.Event{Name: "lunch", StartTime: time.Date(2030, 1, 8, 10, 30, 0, 0, time.UTC), EndTime: time.Date(2030, 1, 8, 11, 30, 0, 0, time.UTC)}, Options{Now: now})
+	assert.NoError(t, err)
+	in.Events = append(in.Events, Event{Event: types.Event{Name: "lunch", StartTime: time.Date(2030, 1, 10, 10, 30, 0, 0, time.UTC), EndTime: time.Date(2030, 1, 10, 11, 30, 0, 0, time.UTC)}})
+	sortData(in)
+	_, err = Generate(context.Background(), in, Options{Now: now, Strategy: Spread})
+	assert.ErrorIs(t, err, ErrOverlappingEvents)
+}
@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Strategy names a way of assigning deadlines to the free slots of a timetable
+type Strategy string
+
+const (
+	// Stochastic samples deadlines at random, weighted towards those with the least time
+	// left, retrying until the sample meets every deadline
+	Stochastic Strategy = "stochastic"
+	// Spread deterministically gives each slot to the deadline with the most work left
+	// per slot available, unless that would leave a deadline unmet, so it needs one pass
+	Spread Strategy = "spread"
+)
+
+// scheduler assigns the work left on deadlines to the free slots of a timetable,
+// assuming it is possible
+type scheduler interface {
+	schedule(ctx context.Context, rng *rand.Rand, timetable []timetableElement, deadlines []deadline) error
+}
+
+// schedulers makes the scheduler for each strategy
+var schedulers = map[Strategy]func(Options) scheduler{
+	Stochastic: func(opts Options) scheduler {
+		return stochasticScheduler{maxAttempts: opts.maxAttempts(), timeout: opts.timeout(), switchPenalty: opts.SwitchPenalty}
+	},
+	Spread: func(opts Options) scheduler {
+		return spreadScheduler{switchPenalty: opts.SwitchPenalty}
+	},
+}
+
+// Strategies lists the strategies that can be chosen
+func Strategies() []Strategy {
+	strategies := make([]Strategy, 0, len(schedulers))
+	for strategy := range schedulers {
+		strategies = append(strategies, strategy)
+	}
+	sort.Slice(strategies, func(p, q int) bool {
+		return strategies[p] < strategies[q]
+	})
+	return strategies
+}
+
+// getScheduler makes the scheduler for the chosen strategy, defaulting to Stochastic
+func getScheduler(opts Options) (scheduler, error) {
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = Stochastic
+	}
+	makeScheduler, ok := schedulers[strategy]
+	if !ok {
+		names := make([]string, 0, len(schedulers))
+		for _, known := range Strategies() {
+			names = append(names, string(known))
+		}
+		return nil, fmt.Errorf("unknown strategy %q, choose one of %s", strategy, strings.Join(names, ", "))
+	}
+	return makeScheduler(opts), nil
+}
+
+// stochasticScheduler gives up on sampling after maxAttempts passes or once timeout has
+// passed, and falls back to spreading the deadlines deterministically
+type stochasticScheduler struct {
+	maxAttempts   int
+	timeout       time.Duration
+	switchPenalty float64
+}
+
+func (s stochasticScheduler) schedule(ctx context.Context, rng *rand.Rand, timetable []timetableElement, deadlines []deadline) error {
+	fillCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	err := fillTimetable(fillCtx, rng, timetable, deadlines, s.maxAttempts, s.switchPenalty)
+	// only our own timeout is grounds to fall back, the caller giving up is not
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	log.Warnf("falling back to the %s strategy, as the %s strategy %s", Spread, Stochastic, err)
+	// clear out the failed attempt
+	for i := range timetable {
+		if !timetable[i].frozen {
+			timetable[i].deadline = nil
+		}
+	}
+	return spreadScheduler{switchPenalty: s.switchPenalty}.schedule(ctx, rng, timetable, deadlines)
+}
+
+type spreadScheduler struct {
+	switchPenalty float64
+}
+
+func (s spreadScheduler) schedule(ctx context.Context, _ *rand.Rand, timetable []timetableElement, deadlines []deadline) error {
+	var current worked
+	deadlinesCopy := copyDeadlines(deadlines)
+	for i, slot := range timetable {
+		current = current.at(timetable, i)
+		if !slot.free() || len(deadlinesCopy) == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chosenIndex, err := spreadChoice(timetable, i, deadlinesCopy, current, s.switchPenalty)
+		if err != nil {
+			return err
+		}
+		if chosenIndex < 0 {
+			passSlot(deadlinesCopy)
+			current = current.next(nil)
+			continue
+		}
+		timetable[i].deadline = deadlinesCopy[chosenIndex].Deadline
+		current = current.next(timetable[i].deadline)
+		deadlinesCopy = reduceDeadlines(deadlinesCopy, chosenIndex)
+	}
+	return nil
+}
+
+// spreadChoice picks the deadline with the most work remaining per slot available that can
+// have the slot at index while leaving time for the others, keeping to block lengths and
+// daily limits, or -1 if none can be worked on in it, with an *InfeasibleError if they
+// cannot be kept
+// the one of those that can be worked on which must be done earliest, once dependencies are
+// taken into account, always can, as no deadline needs the slot more
+func spreadChoice(timetable []timetableElement, index int, deadlines []deadline, current worked, switchPenalty float64) (int, error) {
+	open := openDeadlines(deadlines)
+	if len(open) == 0 {
+		return -1, nil
+	}
+	earliest := open[0]
+	_, order := windows(deadlines)
+	for _, i := range order {
+		if contains(open, i) {
+			earliest = i
+			break
+		}
+	}
+	candidates, err := allowedCandidates(timetable, index, deadlines, open, current)
+	if err != nil || len(candidates) == 0 {
+		return -1, err
+	}
+	weights := getWeights(deadlines, 1)
+	penaliseSwitching(weights, deadlines, candidates, current.run, switchPenalty)
+	// deadlines are sorted, so ties go to the earliest
+	sort.SliceStable(candidates, func(p, q int) bool {
+		return weights[candidates[p]] > weights[candidates[q]]
+	})
+	for _, candidate := range candidates {
+		if candidate == earliest || stillPossible(deadlines, candidate) {
+			return candidate, nil
+		}
+	}
+	return earliest, nil
+}
+
+// contains says whether index is one of indices
+func contains(indices []int, index int) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// stillPossible checks whether every deadline could be met after giving the next slot to
+// the deadline at index
+func stillPossible(deadlines []deadline, index int) bool {
+	_, _, possible := possibleTimetabling(reduceDeadlines(copyDeadlines(deadlines), index))
+	return possible
+}
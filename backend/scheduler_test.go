@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpreadScheduler(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	// every free slot is needed, and the earliest deadline has the least work per slot
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "short", MinutesRemaining: 50, DeadlineTime: now.Add(2 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "long", MinutesRemaining: 250, DeadlineTime: now.Add(7 * time.Hour)}},
+			{Deadline: types.Deadline{Name: "tight", MinutesRemaining: 50, DeadlineTime: now.Add(3 * time.Hour)}},
+		},
+	}
+	sortData(in)
+	timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: Spread})
+	assert.NoError(t, err)
+
+	var names []string
+	for _, slot := range timetable.Slots {
+		assert.Equal(t, DeadlineSlot, slot.Kind)
+		assert.False(t, slot.WorkEnd.After(slot.Deadline.DeadlineTime))
+		names = append(names, slot.Deadline.Name)
+	}
+	assert.Len(t, names, 14)
+	seed := int64(7)
+	again, err := Generate(context.Background(), in, Options{Now: now, Strategy: Spread, Seed: &seed})
+	assert.NoError(t, err)
+	for i, slot := range again.Slots {
+		assert.Equal(t, names[i], slot.Deadline.Name)
+	}
+}
+
+func TestGetScheduler(t *testing.T) {
+	defaulted, err := getScheduler(Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, stochasticScheduler{maxAttempts: DefaultMaxAttempts, timeout: DefaultTimeout}, defaulted)
+
+	_, err = getScheduler(Options{Strategy: "alphabetical"})
+	assert.Error(t, err)
+}
+
+func TestStochasticSchedulerFallsBack(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 100, DeadlineTime: now.Add(4 * time.Hour)}},
+		},
+	}
+	// too short a timeout to make any attempt, so the deadline is spread instead
+	timetable, err := Generate(context.Background(), in, Options{Now: now, Timeout: time.Nanosecond})
+	assert.NoError(t, err)
+	for _, slot := range timetable.Slots[:4] {
+		assert.Equal(t, DeadlineSlot, slot.Kind)
+	}
+
+	// but the caller giving up is an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Generate(ctx, in, Options{Now: now})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestHasFilledDegenerateWeights(t *testing.T) {
+	essay := &Deadline{Deadline: types.Deadline{Name: "essay"}}
+	deadlines := []deadline{{Deadline: essay, slotsRemaining: 2, slotsAvailable: 10}}
+	_, err := hasFilled(nil, make([]timetableElement, 10), deadlines, 1000, 0)
+	assert.ErrorIs(t, err, errDegenerateWeights)
+}
+
+func TestPriorityFrontLoads(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	due := now.Add(8 * time.Hour)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "nice to have", MinutesRemaining: 100, DeadlineTime: due}},
+			{Deadline: types.Deadline{Name: "deliverable", MinutesRemaining: 100, DeadlineTime: due, Priority: 1}},
+		},
+	}
+	sortData(in)
+	assert.Equal(t, "deliverable", in.Deadlines[0].Name)
+
+	weights := getWeights([]deadline{
+		{Deadline: &in.Deadlines[0], slotsRemaining: 4, slotsAvailable: 16},
+		{Deadline: &in.Deadlines[1], slotsRemaining: 4, slotsAvailable: 16},
+	}, 1)
+	assert.Equal(t, 2*weights[1], weights[0])
+
+	// most of the deliverable's work comes before the nice to have's
+	timetable, err := Generate(context.Background(), in, Options{Now: now, Strategy: Spread})
+	assert.NoError(t, err)
+	var names []string
+	for _, slot := range timetable.Slots {
+		if slot.Kind == DeadlineSlot {
+			names = append(names, slot.Deadline.Name)
+		}
+	}
+	assert.Equal(t, []string{"deliverable", "deliverable", "deliverable", "nice to have"}, names[:4])
+}
+
+func TestHasFilledClearsFailedAttempts(t *testing.T) {
+	essay := &Deadline{Deadline: types.Deadline{Name: "essay", MinBlock: 2}}
+	kept := &Deadline{Deadline: types.Deadline{Name: "kept"}}
+	// an earlier attempt gave the first slots away before the essay's start, and one is frozen
+	timetable := make([]timetableElement, 6)
+	timetable[0].deadline = essay
+	timetable[1].deadline = essay
+	timetable[5] = timetableElement{deadline: kept, frozen: true}
+	deadlines := []deadline{{Deadline: essay, slotsRemaining: 2, slotsAvailable: 4, slotsBeforeStart: 2, slotsUsable: 2}}
+	filled, err := hasFilled(rand.New(rand.NewSource(1)), timetable, deadlines, 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, filled)
+	var names []string
+	for _, slot := range timetable {
+		name := ""
+		if slot.deadline != nil {
+			name = slot.deadline.Name
+		}
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"", "", "essay", "essay", "", "kept"}, names)
+}
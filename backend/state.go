@@ -0,0 +1,129 @@
+package backend
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+)
+
+// State is what is kept of a generated timetable between runs, so that planning again can
+// keep to the work it planned soonest rather than starting afresh
+type State struct {
+	Start time.Time `json:"start"`
+	// End is when the last slot of the timetable ends, or nil for no limit
+	End  *time.Time `json:"end,omitempty"`
+	Seed int64      `json:"seed"`
+	// Work are the slots given to deadlines
+	Work []StateSlot `json:"work"`
+}
+
+// StateSlot is a slot given to a deadline, known by its name and the file it is set in
+type StateSlot struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Deadline string    `json:"deadline"`
+	Source   string    `json:"source,omitempty"`
+}
+
+// State keeps the slots of the timetable given to deadlines
+func (t *Timetable) State() *State {
+	state := &State{Start: t.Start, Seed: t.Seed, Work: []StateSlot{}}
+	if len(t.Slots) > 0 {
+		end := t.Slots[len(t.Slots)-1].End
+		state.End = &end
+	}
+	for _, slot := range t.Slots {
+		if slot.Kind == DeadlineSlot {
+			state.Work = append(state.Work, StateSlot{Start: slot.Start, End: slot.End, Deadline: slot.Deadline.Name, Source: slot.Deadline.Source})
+		}
+	}
+	return state
+}
+
+// EntriesState keeps the entries given to deadlines, as written by a timetable's Entries, with
+// each slot ending with the break after it
+func EntriesState(entries []Entry) *State {
+	state := &State{Work: []StateSlot{}}
+	if len(entries) > 0 {
+		state.Start = entries[0].Start
+		end := entries[len(entries)-1].End
+		state.End = &end
+	}
+	for i, entry := range entries {
+		if entry.Kind != DeadlineSlot {
+			continue
+		}
+		slot := StateSlot{Start: entry.Start, End: entry.End, Deadline: entry.Name, Source: entry.Source}
+		if i+1 < len(entries) && entries[i+1].Kind == BreakSlot {
+			slot.End = entries[i+1].End
+		}
+		state.Work = append(state.Work, slot)
+	}
+	return state
+}
+
+// ReadState reads a state written by WriteJSON
+func ReadState(r io.Reader) (*State, error) {
+	var state State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// WriteJSON writes the state as JSON
+func (s *State) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// fillWithFrozen gives the slots starting within horizon back to the deadlines previous gave
+// them to, as long as they are still free and in the deadlines' windows, and no more of them
+// than the work left on each deadline needs, returning how many slots it kept
+func fillWithFrozen(g grid, timetable []timetableElement, deadlines []Deadline, previous *State, horizon time.Duration) int {
+	if previous == nil || horizon <= 0 {
+		return 0
+	}
+	byName := map[[2]string]*Deadline{}
+	for i, deadline := range deadlines {
+		key := [2]string{deadline.Name, deadline.Source}
+		if _, ok := byName[key]; !ok {
+			byName[key] = &deadlines[i]
+		}
+	}
+	end := clamp(g.ceil(g.start.Add(horizon)), len(timetable))
+	frozen := map[*Deadline]int{}
+	kept := 0
+	for _, slot := range previous.Work {
+		index := g.floor(slot.Start)
+		// slots of a different length than before don't line up, so can't be kept
+		if index < 0 || index >= end || !g.slotStart(index).Equal(slot.Start) || !timetable[index].free() {
+			continue
+		}
+		deadline, ok := byName[[2]string{slot.Deadline, slot.Source}]
+		if !ok || index >= g.floor(deadline.DeadlineTime) || (deadline.StartTime != nil && index < g.ceil(*deadline.StartTime)) {
+			continue
+		}
+		if frozen[deadline] >= int(math.Ceil(deadline.MinutesRemaining/g.Work.Minutes())) {
+			continue
+		}
+		timetable[index].deadline = deadline
+		timetable[index].frozen = true
+		frozen[deadline]++
+		kept++
+	}
+	return kept
+}
+
+// frozenSlots counts the slots kept for the deadline from a previous timetable
+func frozenSlots(timetable []timetableElement, d *Deadline) int {
+	count := 0
+	for _, slot := range timetable {
+		if slot.frozen && slot.deadline == d {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// deadlineNames lists the deadline each slot starting before until is given to, if any
+func deadlineNames(timetable *Timetable, until time.Time) (names []string) {
+	for _, slot := range timetable.Slots {
+		if !slot.Start.Before(until) {
+			break
+		}
+		name := ""
+		if slot.Kind == DeadlineSlot {
+			name = slot.Deadline.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestGenerateFrozen(t *testing.T) {
+	now := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	in := &Input{
+		Deadlines: []Deadline{
+			{Deadline: types.Deadline{Name: "report", MinutesRemaining: 300, DeadlineTime: now.Add(48 * time.Hour)}, Source: ".at.toml"},
+			{Deadline: types.Deadline{Name: "essay", MinutesRemaining: 200, DeadlineTime: now.Add(72 * time.Hour)}, Source: ".at.toml"},
+		},
+	}
+	first, err := Generate(context.Background(), in, Options{Now: now})
+	assert.NoError(t, err)
+
+	// the state reads back as it was written
+	var buffer bytes.Buffer
+	assert.NoError(t, first.State().WriteJSON(&buffer))
+	state, err := ReadState(&buffer)
+	assert.NoError(t, err)
+	assert.Equal(t, first.State(), state)
+
+	// a state with no slots has no end
+	buffer.Reset()
+	assert.NoError(t, EntriesState(nil).WriteJSON(&buffer))
+	assert.NotContains(t, buffer.String(), `"end"`)
+
+	// a deadline added next month leaves the next four hours as they were, even with a new seed
+	in.Deadlines = append(in.Deadlines, Deadline{Deadline: types.Deadline{Name: "thesis", MinutesRemaining: 1000, DeadlineTime: now.Add(30 * 24 * time.Hour)}, Source: ".at.toml"})
+	later := now.Add(time.Hour)
+	seed := int64(7)
+	second, err := Generate(context.Background(), in, Options{Now: later, Seed: &seed, Previous: state, FreezeHorizon: 4 * time.Hour})
+	assert.NoError(t, err)
+	kept := deadlineNames(first, later.Add(4*time.Hour))[2:]
+	assert.Equal(t, kept, deadlineNames(second, later.Add(4*time.Hour)))
+	count := map[string]int{}
+	for _, slot := range second.Slots {
+		if slot.Kind == DeadlineSlot {
+			count[slot.Deadline.Name]++
+		}
+	}
+	assert.Equal(t, map[string]int{"report": 12, "essay": 8, "thesis": 40}, count)
+
+	// nor is work that has been done kept
+	in.Deadlines[0].MinutesRemaining = 25
+	second, err = Generate(context.Background(), in, Options{Now: later, Previous: state, FreezeHorizon: 4 * time.Hour})
+	assert.NoError(t, err)
+	count = map[string]int{}
+	for _, slot := range second.Slots {
+		if slot.Kind == DeadlineSlot {
+			count[slot.Deadline.Name]++
+		}
+	}
+	assert.Equal(t, 1, count["report"])
+
+	// keeping slots that an urgent deadline needs means planning afresh
+	in.Deadlines = append(in.Deadlines, Deadline{Deadline: types.Deadline{Name: "urgent", MinutesRemaining: 200, DeadlineTime: later.Add(4 * time.Hour)}, Source: ".at.toml"})
+	sortDeadlines(in.Deadlines)
+	_, err = Generate(context.Background(), in, Options{Now: later, Previous: state, FreezeHorizon: 4 * time.Hour})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,208 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SlotKind says what a slot of the timetable is being used for
+type SlotKind int
+
+const (
+	FreeSlot SlotKind = iota
+	EventSlot
+	DeadlineSlot
+	// BreakSlot is only used by entries, for the break after the work in a deadline's slot
+	BreakSlot
+	// OffSlot is a slot outside the times deadlines can be worked on
+	OffSlot
+	// HabitSlot is a slot taken up by a habit
+	HabitSlot
+)
+
+var slotKindNames = map[SlotKind]string{
+	FreeSlot:     "free",
+	EventSlot:    "event",
+	DeadlineSlot: "deadline",
+	BreakSlot:    "break",
+	OffSlot:      "off",
+	HabitSlot:    "habit",
+}
+
+func (k SlotKind) String() string {
+	return slotKindNames[k]
+}
+
+// MarshalText writes the kind as its name
+func (k SlotKind) MarshalText() ([]byte, error) {
+	name, ok := slotKindNames[k]
+	if !ok {
+		return nil, fmt.Errorf("unknown slot kind %d", int(k))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText reads the kind from its name
+func (k *SlotKind) UnmarshalText(text []byte) error {
+	for kind, name := range slotKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown slot kind %q", text)
+}
+
+// Slot is a single slot of a generated timetable
+type Slot struct {
+	Start time.Time
+	End   time.Time
+	// WorkEnd and BreakEnd are when work on a deadline stops and when the break after it ends
+	WorkEnd   time.Time
+	BreakEnd  time.Time
+	Kind      SlotKind
+	Event     *Event
+	Deadline  *Deadline
+	Habit     *Periodic
+	Periodics []Periodic
+}
+
+// Timetable is a generated timetable, made up of consecutive slots
+type Timetable struct {
+	Start time.Time
+	// Seed is the seed the timetable was generated with, which will generate it again
+	Seed  int64
+	Slots []Slot
+	// Shortfalls are the deadlines a best-effort timetable leaves work undone on
+	Shortfalls []Shortfall
+}
+
+// Shortfall is how many minutes of work on a deadline there was no time for
+type Shortfall struct {
+	Deadline *Deadline
+	Minutes  float64
+}
+
+// newTimetable turns the filled timetable elements into a Timetable
+func newTimetable(g grid, seed int64, timetable []timetableElement) *Timetable {
+	slots := make([]Slot, len(timetable))
+	for i, element := range timetable {
+		slots[i] = Slot{
+			Start:     g.slotStart(i),
+			End:       g.slotStart(i + 1),
+			Event:     element.event,
+			Deadline:  element.deadline,
+			Habit:     element.habit,
+			Periodics: element.periodics,
+		}
+		switch {
+		case element.event != nil:
+			slots[i].Kind = EventSlot
+		case element.habit != nil:
+			slots[i].Kind = HabitSlot
+		case element.deadline != nil:
+			slots[i].Kind = DeadlineSlot
+			slots[i].WorkEnd = slots[i].Start.Add(g.Work)
+			slots[i].BreakEnd = slots[i].WorkEnd.Add(g.Break)
+		case element.off:
+			slots[i].Kind = OffSlot
+		}
+	}
+	return &Timetable{Start: g.start, Seed: seed, Slots: slots}
+}
+
+// Entry is a stretch of the timetable given over to one thing, in a form for other programs
+// to read
+type Entry struct {
+	Start     time.Time       `json:"start"`
+	End       time.Time       `json:"end"`
+	Kind      SlotKind        `json:"kind"`
+	Name      string          `json:"name,omitempty"`
+	Source    string          `json:"source,omitempty"`
+	Periodics []EntryPeriodic `json:"periodics,omitempty"`
+}
+
+// EntryPeriodic is a periodic attached to an entry
+type EntryPeriodic struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+}
+
+// Entries lists the timetable's slots as entries, with work on a deadline and the break
+// after it as separate entries
+func (t *Timetable) Entries() []Entry {
+	entries := make([]Entry, 0, len(t.Slots))
+	for _, slot := range t.Slots {
+		entry := Entry{Start: slot.Start, End: slot.End, Kind: slot.Kind}
+		for _, periodic := range slot.Periodics {
+			entry.Periodics = append(entry.Periodics, EntryPeriodic{Name: periodic.Name, Source: periodic.Source})
+		}
+		switch slot.Kind {
+		case EventSlot:
+			entry.Name, entry.Source = slot.Event.Name, slot.Event.Source
+		case HabitSlot:
+			entry.Name, entry.Source = slot.Habit.Name, slot.Habit.Source
+		case DeadlineSlot:
+			entry.Name, entry.Source = slot.Deadline.Name, slot.Deadline.Source
+			entry.End = slot.WorkEnd
+		}
+		entries = append(entries, entry)
+		if slot.Kind == DeadlineSlot && slot.BreakEnd.After(slot.WorkEnd) {
+			entries = append(entries, Entry{Start: slot.WorkEnd, End: slot.BreakEnd, Kind: BreakSlot})
+		}
+	}
+	return entries
+}
+
+// First returns a timetable of just the first n slots
+func (t *Timetable) First(n int) *Timetable {
+	if n >= len(t.Slots) {
+		return t
+	}
+	return &Timetable{Start: t.Start, Seed: t.Seed, Slots: t.Slots[:n], Shortfalls: t.Shortfalls}
+}
+
+// String prints the timetable as-is
+func (t *Timetable) String() string {
+	builder := strings.Builder{}
+	// the deadlines worked on so far, which were still to be done when the timetable starts
+	worked := map[string]bool{}
+	for _, slot := range t.Slots {
+		switch slot.Kind {
+		case EventSlot:
+			builder.WriteString(fmt.Sprintf("%s-%s: ", slot.Start.Format("Jan 2 15:04"), slot.End.Format("Jan 2 15:04")))
+			builder.WriteString(fmt.Sprintf("[EVENT] %s", slot.Event.Name))
+		case HabitSlot:
+			builder.WriteString(fmt.Sprintf("%s-%s: ", slot.Start.Format("Jan 2 15:04"), slot.End.Format("Jan 2 15:04")))
+			builder.WriteString(fmt.Sprintf("[HABIT] %s", slot.Habit.Name))
+		case DeadlineSlot:
+			builder.WriteString(fmt.Sprintf("%s-%s: ", slot.Start.Format("Jan 2 15:04"), slot.WorkEnd.Format("Jan 2 15:04")))
+			builder.WriteString(fmt.Sprintf("[DEADLINE] %s", slot.Deadline.Name))
+			// work blocked until other deadlines are done says what it was waiting for
+			var waiting []string
+			for _, prerequisite := range slot.Deadline.DependsOn {
+				if worked[prerequisite] {
+					waiting = append(waiting, prerequisite)
+				}
+			}
+			if len(waiting) > 0 {
+				builder.WriteString(fmt.Sprintf(" (after %s)", strings.Join(waiting, ", ")))
+			}
+			worked[slot.Deadline.Name] = true
+			if slot.BreakEnd.After(slot.WorkEnd) {
+				builder.WriteString(fmt.Sprintln())
+				builder.WriteString(fmt.Sprintf("%s-%s: %d minute break", slot.WorkEnd.Format("Jan 2 15:04"), slot.BreakEnd.Format("Jan 2 15:04"), int(slot.BreakEnd.Sub(slot.WorkEnd).Minutes())))
+			}
+		case OffSlot:
+			builder.WriteString(fmt.Sprintf("%s-%s: OFF", slot.Start.Format("Jan 2 15:04"), slot.End.Format("Jan 2 15:04")))
+		default:
+			builder.WriteString(fmt.Sprintf("%s-%s: FREE SLOT", slot.Start.Format("Jan 2 15:04"), slot.End.Format("Jan 2 15:04")))
+		}
+		for _, periodic := range slot.Periodics {
+			builder.WriteString(fmt.Sprintf(" ; [PERIODIC] %s", periodic.Name))
+		}
+		builder.WriteString(fmt.Sprintln())
+	}
+	return builder.String()
+}
@@ -0,0 +1,32 @@
+package backend
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEntries(t *testing.T) {
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC)
+	g := grid{start: start, Durations: DefaultDurations}
+	essay := &Deadline{Deadline: types.Deadline{Name: "essay"}, Source: "a/.at.toml"}
+	walk := Periodic{Periodic: types.Periodic{Name: "walk"}, Source: ".at.toml"}
+	timetable := newTimetable(g, 1, []timetableElement{{deadline: essay, periodics: []Periodic{walk}}, {}})
+
+	entries := timetable.Entries()
+	assert.Equal(t, []Entry{
+		{Start: start, End: start.Add(25 * time.Minute), Kind: DeadlineSlot, Name: "essay", Source: "a/.at.toml", Periodics: []EntryPeriodic{{Name: "walk", Source: ".at.toml"}}},
+		{Start: start.Add(25 * time.Minute), End: start.Add(30 * time.Minute), Kind: BreakSlot},
+		{Start: start.Add(30 * time.Minute), End: start.Add(time.Hour), Kind: FreeSlot},
+	}, entries)
+
+	// entries read back as they were written
+	encoded, err := json.Marshal(entries)
+	assert.NoError(t, err)
+	var decoded []Entry
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, entries, decoded)
+}
@@ -1,13 +1,156 @@
 package cli
 
-import "fmt"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
-// TODO: implement logic for this
-func AddEvent(_ *string) {
-	fmt.Println("add event")
+	"github.com/mhbardsley/auto-timetable/backend"
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/pelletier/go-toml/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// EventArgs holds the command-line arguments for adding an event
+type EventArgs struct {
+	Dir       string
+	File      string
+	Name      string
+	StartTime string
+	EndTime   string
+	// Now is the time the event must not have passed by, or empty for the current time
+	Now string
+}
+
+// AddEvent checks the event against the tree in args.Dir and appends it to args.File
+func AddEvent(args EventArgs) error {
+	startTime, err := parseTime(args.StartTime)
+	if err != nil {
+		return fmt.Errorf("could not parse start time: %w", err)
+	}
+	endTime, err := parseTime(args.EndTime)
+	if err != nil {
+		return fmt.Errorf("could not parse end time: %w", err)
+	}
+	opts, err := planOptions(args.Now, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	newEvent := types.Event{Name: args.Name, StartTime: startTime, EndTime: endTime}
+	if err := backend.CheckEvent(args.Dir, newEvent, opts); err != nil {
+		return fmt.Errorf("could not add event: %w", err)
+	}
+	return appendToml(targetFile(args.Dir, args.File), struct {
+		Events []types.Event `toml:"events"`
+	}{[]types.Event{newEvent}})
+}
+
+// DeadlineArgs holds the command-line arguments for adding a deadline
+type DeadlineArgs struct {
+	Dir              string
+	File             string
+	Name             string
+	MinutesRemaining float64
+	Deadline         string
+	// StartTime, if not empty, is the earliest the deadline can be worked on
+	StartTime string
+	// DependsOn names the deadlines that have to be done first
+	DependsOn []string
+	// MinBlock and MaxBlock bound the slots in a row spent on the deadline, unless 0
+	MinBlock int
+	MaxBlock int
+	// MaxMinutesPerDay limits the work on the deadline planned in a day, unless 0
+	MaxMinutesPerDay int
+	Priority         int
+	// Force adds the deadline even if it leaves too little time for everything
+	Force bool
+	// Now is the time to check there is time for every deadline from, or empty for the
+	// current time
+	Now string
+}
+
+// AddDeadline checks the deadline can still be met alongside the tree in args.Dir and
+// appends it to args.File
+func AddDeadline(args DeadlineArgs) error {
+	deadlineTime, err := parseTime(args.Deadline)
+	if err != nil {
+		return fmt.Errorf("could not parse deadline: %w", err)
+	}
+	newDeadline := types.Deadline{Name: args.Name, MinutesRemaining: args.MinutesRemaining, DeadlineTime: deadlineTime, DependsOn: args.DependsOn, MinBlock: args.MinBlock, MaxBlock: args.MaxBlock, MaxMinutesPerDay: args.MaxMinutesPerDay, Priority: args.Priority}
+	if args.StartTime != "" {
+		startTime, err := parseTime(args.StartTime)
+		if err != nil {
+			return fmt.Errorf("could not parse start time: %w", err)
+		}
+		newDeadline.StartTime = &startTime
+	}
+	opts, err := planOptions(args.Now, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	err = backend.CheckDeadline(args.Dir, newDeadline, opts)
+	var infeasibleErr *backend.InfeasibleError
+	switch {
+	case errors.As(err, &infeasibleErr) && args.Force:
+		log.Warnf("adding deadline %s anyway: %s", args.Name, err)
+	case err != nil:
+		return fmt.Errorf("could not add deadline: %w", err)
+	}
+	if newDeadline.StartTime == nil {
+		return appendToml(targetFile(args.Dir, args.File), struct {
+			Deadlines []types.Deadline `toml:"deadlines"`
+		}{[]types.Deadline{newDeadline}})
+	}
+	// go-toml writes a *time.Time as a string, which it will not read back as a time
+	started := startedDeadline{Deadline: newDeadline, StartTime: *newDeadline.StartTime}
+	started.Deadline.StartTime = nil
+	return appendToml(targetFile(args.Dir, args.File), struct {
+		Deadlines []startedDeadline `toml:"deadlines"`
+	}{[]startedDeadline{started}})
+}
+
+// startedDeadline is a deadline with a start time, written as a TOML date-time
+type startedDeadline struct {
+	types.Deadline
+	StartTime time.Time `toml:"startTime"`
+}
+
+// targetFile is the .at.toml to write to, defaulting to the one at the top of the tree
+func targetFile(dirName string, fileName string) string {
+	if fileName != "" {
+		return fileName
+	}
+	return filepath.Join(dirName, ".at.toml")
 }
 
-// TODO: implement logic for this
-func AddDeadline(_ *string) {
-	fmt.Println("add deadline")
+// appendToml marshals v and appends it to the end of the toml file, leaving what is
+// already there untouched
+func appendToml(fileName string, v interface{}) error {
+	encoded, err := toml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not encode toml: %w", err)
+	}
+	existing, err := os.ReadFile(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read %s: %w", fileName, err)
+	}
+	// separate the new table from whatever came before it
+	if len(existing) > 0 {
+		if !bytes.HasSuffix(existing, []byte("\n")) {
+			encoded = append([]byte("\n"), encoded...)
+		}
+		encoded = append([]byte("\n"), encoded...)
+	}
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", fileName, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(encoded); err != nil {
+		return fmt.Errorf("could not write to %s: %w", fileName, err)
+	}
+	return nil
 }
@@ -1 +1,69 @@
-package cli
\ No newline at end of file
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+// testNow is the time the tests add from
+const testNow = "2030-01-01T09:00:00Z"
+
+func TestAddEvent(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, ".at.toml")
+	existing := "# my events\n[[deadlines]]\nname = \"essay\"\nminutesRemaining = 50.0\ndeadline = 2030-01-10T12:00:00Z"
+	assert.NoError(t, os.WriteFile(fileName, []byte(existing), 0644))
+
+	err := AddEvent(EventArgs{Dir: dir, Name: "meeting", StartTime: "2030-01-02T10:00:00Z", EndTime: "2030-01-02T11:00:00Z", Now: testNow})
+	assert.NoError(t, err)
+	written, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Contains(t, string(written), existing+"\n\n[[events]]\nname = 'meeting'\n")
+
+	// overlaps the event just added
+	err = AddEvent(EventArgs{Dir: dir, Name: "clash", StartTime: "2030-01-02T10:30:00Z", EndTime: "2030-01-02T12:00:00Z", Now: testNow})
+	assert.Error(t, err)
+
+	err = AddEvent(EventArgs{Dir: dir, Name: "backwards", StartTime: "2030-01-03T12:00:00Z", EndTime: "2030-01-03T11:00:00Z", Now: testNow})
+	assert.Error(t, err)
+}
+
+func TestAddDeadline(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, ".at.toml")
+	soon := "2030-01-01T12:00:00Z"
+
+	err := AddDeadline(DeadlineArgs{Dir: dir, Name: "report", MinutesRemaining: 50, Deadline: soon, Now: testNow})
+	assert.NoError(t, err)
+
+	later := "2030-01-01T11:00:00Z"
+	err = AddDeadline(DeadlineArgs{Dir: dir, Name: "review", MinutesRemaining: 25, Deadline: soon, StartTime: later, Now: testNow})
+	assert.NoError(t, err)
+	err = AddDeadline(DeadlineArgs{Dir: dir, Name: "review", MinutesRemaining: 25, Deadline: soon, StartTime: "after lunch", Now: testNow})
+	assert.Error(t, err)
+
+	// far more work than fits before the deadline
+	var infeasibleErr *backend.InfeasibleError
+	err = AddDeadline(DeadlineArgs{Dir: dir, Name: "thesis", MinutesRemaining: 600, Deadline: soon, Now: testNow})
+	assert.ErrorAs(t, err, &infeasibleErr)
+
+	err = AddDeadline(DeadlineArgs{Dir: dir, Name: "thesis", MinutesRemaining: 600, Deadline: soon, Force: true, Now: testNow})
+	assert.NoError(t, err)
+	written, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Contains(t, string(written), "name = 'report'")
+	assert.Contains(t, string(written), "name = 'thesis'")
+	assert.Contains(t, string(written), "startTime = ")
+
+	// the start time reads back as a time
+	review, err := backend.FindDeadline(dir, "review")
+	assert.NoError(t, err)
+	if assert.NotNil(t, review.StartTime) {
+		assert.Equal(t, later, review.StartTime.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+)
+
+// CheckArgs holds the command-line arguments for checking there is time for every deadline
+type CheckArgs struct {
+	// Generate holds the arguments for the timetable that would be generated
+	Generate GenerateArgs
+}
+
+// Check loads the tree in args.Dir and writes a report on the capacity for each deadline
+// to w; if there is too little time, the report is part of the *backend.InfeasibleError
+// returned instead
+func Check(w io.Writer, args CheckArgs) error {
+	opts, err := generateOptions(args.Generate)
+	if err != nil {
+		return err
+	}
+	input, err := backend.Load(args.Generate.Dir, args.Generate.ICS...)
+	if err != nil {
+		return err
+	}
+	report, err := backend.Check(input, opts)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(w, report)
+	return err
+}
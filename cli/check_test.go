@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	input := "[[deadlines]]\nname = \"essay\"\nminutesRemaining = 50\ndeadline = 2030-01-07T10:30:00Z\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(input), 0644))
+
+	var out bytes.Buffer
+	err := Check(&out, CheckArgs{Generate: GenerateArgs{Dir: dir, Now: "2030-01-07T09:00:00Z"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Capacity from Jan 7 09:00\nJan 7 10:30 essay: 2 slot(s) of work and 3 free since the last deadline, 2 and 3 in total\n", out.String())
+
+	// too little time is an error holding the report
+	out.Reset()
+	err = Check(&out, CheckArgs{Generate: GenerateArgs{Dir: dir, Now: "2030-01-07T10:00:00Z"}})
+	var infeasibleErr *backend.InfeasibleError
+	assert.ErrorAs(t, err, &infeasibleErr)
+	assert.Contains(t, err.Error(), "Suggestions:\n  push deadline essay by 30 minutes\n  trim deadline essay by 25 minutes\n")
+	assert.Empty(t, out.String())
+
+	// as generate would, it keeps to the daily limit given
+	out.Reset()
+	err = Check(&out, CheckArgs{Generate: GenerateArgs{Dir: dir, Now: "2030-01-07T09:00:00Z", MaxMinutesPerDay: 25}})
+	assert.ErrorAs(t, err, &infeasibleErr)
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+)
+
+// timeLayouts are the formats accepted for times given on the command line
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// parseTime parses a time in any of timeLayouts, using the local timezone if none is given
+func parseTime(timeStr string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if parsed, err := time.ParseInLocation(layout, timeStr, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not in a recognised format, try e.g. \"2006-01-02 15:04\"", timeStr)
+}
+
+// planOptions makes the backend options shared by the commands that plan from the input,
+// where now is empty for the current time and the minutes override the settings unless 0
+func planOptions(now string, slotMinutes, workMinutes, breakMinutes int) (backend.Options, error) {
+	opts := backend.Options{
+		Durations: backend.Durations{
+			Slot:  time.Duration(slotMinutes) * time.Minute,
+			Work:  time.Duration(workMinutes) * time.Minute,
+			Break: time.Duration(breakMinutes) * time.Minute,
+		},
+	}
+	if now != "" {
+		parsed, err := parseTime(now)
+		if err != nil {
+			return opts, fmt.Errorf("could not parse now: %w", err)
+		}
+		opts.Now = parsed
+	}
+	return opts, nil
+}
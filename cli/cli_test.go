@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	parsed, err := parseTime("2030-01-02 15:04")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2030, 1, 2, 15, 4, 0, 0, time.Local), parsed)
+
+	_, err = parseTime("tomorrow")
+	assert.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+)
+
+// DiffArgs holds the command-line arguments for comparing two timetables
+type DiffArgs struct {
+	// Before and After are timetables saved by generate or written by it in the json format
+	// with After empty, a timetable is generated from Generate to compare with Before, which
+	// defaults to the saved one
+	Before string
+	After  string
+	// Generate holds the arguments for generating a timetable, which is not saved
+	Generate GenerateArgs
+	// Format is text or json, defaulting to text
+	Format string
+}
+
+// Diff compares the work planned on each deadline in two timetables, writing the blocks of
+// work that moved, were added or were removed to w
+func Diff(ctx context.Context, w io.Writer, args DiffArgs) error {
+	if args.Format != "" && args.Format != "text" && args.Format != "json" {
+		return fmt.Errorf("unknown format %q, choose one of text, json", args.Format)
+	}
+	var before, after *backend.State
+	var err error
+	if args.Before != "" {
+		before, err = readPlan(args.Before)
+	} else {
+		before, err = readState(statePath(args.Generate))
+		if err == nil && before == nil {
+			err = fmt.Errorf("could not find a saved timetable in %s, so generate one first", statePath(args.Generate))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if args.After != "" {
+		if after, err = readPlan(args.After); err != nil {
+			return err
+		}
+	} else {
+		timetable, err := generate(ctx, args.Generate)
+		if err != nil {
+			return err
+		}
+		after = timetable.State()
+	}
+	diff := backend.DiffStates(before, after)
+	if args.Format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+	_, err = fmt.Fprint(w, diff)
+	return err
+}
+
+// readPlan reads a timetable saved by generate, or written by it in the json format
+func readPlan(path string) (*backend.State, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	// the json format is a list of entries, where a saved timetable is an object
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) {
+		var entries []backend.Entry
+		if err := json.Unmarshal(content, &entries); err != nil {
+			return nil, fmt.Errorf("could not read the timetable in %s: %w", path, err)
+		}
+		return backend.EntriesState(entries), nil
+	}
+	state, err := backend.ReadState(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the saved timetable in %s: %w", path, err)
+	}
+	return state, nil
+}
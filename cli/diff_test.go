@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	input := "[[deadlines]]\nname = \"essay\"\nminutesRemaining = 50\ndeadline = 2030-01-07T12:00:00Z\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(input), 0644))
+
+	var out bytes.Buffer
+	err := Diff(context.Background(), &out, DiffArgs{Generate: GenerateArgs{Dir: dir}})
+	assert.Error(t, err)
+
+	args := GenerateArgs{Dir: dir, Slots: 6, Now: "2030-01-07T09:00:00Z", Strategy: "spread", Format: "json"}
+	assert.NoError(t, Generate(context.Background(), &out, args))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "before.json"), out.Bytes(), 0644))
+
+	// planning again the same way changes nothing
+	out.Reset()
+	assert.NoError(t, Diff(context.Background(), &out, DiffArgs{Generate: args}))
+	assert.Equal(t, "No changes\n", out.String())
+
+	// nor does writing fewer slots of it, as only the time both cover is compared
+	short := args
+	short.Slots = 1
+	out.Reset()
+	assert.NoError(t, Generate(context.Background(), &out, short))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "short.json"), out.Bytes(), 0644))
+	out.Reset()
+	assert.NoError(t, Diff(context.Background(), &out, DiffArgs{After: filepath.Join(dir, "short.json"), Generate: args}))
+	assert.Equal(t, "No changes\n", out.String())
+
+	// a meeting in the way moves the work
+	input += "\n[[events]]\nname = \"meeting\"\nstartTime = 2030-01-07T09:00:00Z\nendTime = 2030-01-07T11:00:00Z\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(input), 0644))
+	out.Reset()
+	assert.NoError(t, Diff(context.Background(), &out, DiffArgs{Generate: args, Format: "json"}))
+	var diff backend.Diff
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &diff))
+	assert.True(t, diff.Changed())
+
+	// as does comparing the json output of each
+	out.Reset()
+	assert.NoError(t, Generate(context.Background(), &out, args))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "after.json"), out.Bytes(), 0644))
+	out.Reset()
+	assert.NoError(t, Diff(context.Background(), &out, DiffArgs{Before: filepath.Join(dir, "before.json"), After: filepath.Join(dir, "after.json")}))
+	assert.Contains(t, out.String(), "essay: 0 block(s) unchanged\n  moved ")
+}
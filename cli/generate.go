@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+// GenerateArgs holds the command-line arguments for generating a timetable
+type GenerateArgs struct {
+	Dir string
+	// ICS are iCalendar files outside Dir to read events from
+	ICS   []string
+	Slots int
+	// Now is the time to plan from, or empty for the current time
+	Now string
+	// Seed, if set, seeds the timetable, which otherwise uses one derived from the time
+	// planned from
+	Seed *int64
+	// SlotMinutes, WorkMinutes and BreakMinutes override the settings, unless 0
+	SlotMinutes  int
+	WorkMinutes  int
+	BreakMinutes int
+	// Strategy names how deadlines are assigned to slots
+	Strategy string
+	// MaxAttempts and Timeout bound the stochastic strategy, unless 0
+	MaxAttempts int
+	Timeout     time.Duration
+	// Format is one of Formats, defaulting to text
+	Format string
+	// ICSBreaks includes the breaks as events in the ics format
+	ICSBreaks bool
+	// BestEffort generates a timetable even if there is too little time, sharing it out by Policy
+	BestEffort bool
+	Policy     string
+	// SwitchPenalty and MaxMinutesPerDay override the settings, unless 0
+	SwitchPenalty    float64
+	MaxMinutesPerDay int
+	// State is where the timetable is saved for planning again, defaulting to .at.state.json
+	// at the top of the tree
+	State string
+	// Freeze overrides the settings for how far ahead work saved in State is kept, unless 0
+	Freeze time.Duration
+	// Fresh plans without keeping to the saved timetable
+	Fresh bool
+}
+
+// Formats lists the formats a timetable can be written in
+var Formats = []string{"text", "json", "ics"}
+
+// Generate loads the tree in args.Dir and writes its timetable to w, saving it for
+// planning again
+func Generate(ctx context.Context, w io.Writer, args GenerateArgs) error {
+	if err := checkFormat(args.Format); err != nil {
+		return err
+	}
+	timetable, err := generate(ctx, args)
+	if err != nil {
+		return err
+	}
+	if err := writeState(statePath(args), timetable.State()); err != nil {
+		return err
+	}
+	for _, shortfall := range timetable.Shortfalls {
+		log.Warnf("there is no time for %.0f minute(s) of work on %s, due %s", shortfall.Minutes, shortfall.Deadline.Name, shortfall.Deadline.DeadlineTime.Format("Jan 2 15:04"))
+	}
+	return writeTimetable(w, timetable.First(args.Slots), args)
+}
+
+// generate loads the tree in args.Dir and generates its timetable, keeping to the saved one
+// unless args.Fresh is set
+func generate(ctx context.Context, args GenerateArgs) (*backend.Timetable, error) {
+	opts, err := generateOptions(args)
+	if err != nil {
+		return nil, err
+	}
+	input, err := backend.Load(args.Dir, args.ICS...)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Generate(ctx, input, opts)
+}
+
+// generateOptions makes the backend options for generating a timetable, along with the saved
+// one to keep to unless args.Fresh is set
+func generateOptions(args GenerateArgs) (backend.Options, error) {
+	opts, err := planOptions(args.Now, args.SlotMinutes, args.WorkMinutes, args.BreakMinutes)
+	if err != nil {
+		return opts, err
+	}
+	opts.Slots = args.Slots
+	opts.Seed = args.Seed
+	opts.Strategy = backend.Strategy(args.Strategy)
+	opts.MaxAttempts = args.MaxAttempts
+	opts.Timeout = args.Timeout
+	opts.BestEffort = args.BestEffort
+	opts.Policy = backend.Policy(args.Policy)
+	opts.SwitchPenalty = args.SwitchPenalty
+	opts.MaxMinutesPerDay = args.MaxMinutesPerDay
+	opts.FreezeHorizon = args.Freeze
+	if !args.Fresh {
+		if opts.Previous, err = readState(statePath(args)); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// statePath is where the timetable is saved, defaulting to .at.state.json at the top of the tree
+func statePath(args GenerateArgs) string {
+	if args.State == "" {
+		return filepath.Join(args.Dir, ".at.state.json")
+	}
+	return args.State
+}
+
+// readState reads the state saved at path, if there is one
+func readState(path string) (*backend.State, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer file.Close()
+	state, err := backend.ReadState(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the saved timetable in %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// writeState saves the state to path
+func writeState(path string, state *backend.State) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	if err := state.WriteJSON(file); err != nil {
+		file.Close()
+		return fmt.Errorf("could not save the timetable to %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// checkFormat checks the format is one of Formats
+func checkFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, known := range Formats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q, choose one of %s", format, strings.Join(Formats, ", "))
+}
+
+// writeTimetable writes the timetable to w in the format args asks for
+func writeTimetable(w io.Writer, timetable *backend.Timetable, args GenerateArgs) error {
+	switch args.Format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(timetable.Entries())
+	case "ics":
+		return timetable.WriteICS(w, args.ICSBreaks)
+	default:
+		// the seed goes first, so anyone can generate the same timetable again
+		_, err := fmt.Fprintf(w, "Timetable from %s (seed %d)\n%s", timetable.Start.Format("Jan 2 15:04"), timetable.Seed, timetable)
+		return err
+	}
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	input := "[[events]]\nname = \"standup\"\nstartTime = 2030-01-07T09:30:00Z\nendTime = 2030-01-07T10:00:00Z\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(input), 0644))
+
+	var out bytes.Buffer
+	err := Generate(context.Background(), &out, GenerateArgs{Dir: dir, Slots: 2, Now: "2030-01-07T09:05:00Z"})
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, []string{"Timetable from Jan 7 09:30 (seed 1894008600)", "Jan 7 09:30-Jan 7 10:00: [EVENT] standup", "Jan 7 10:00-Jan 7 10:30: FREE SLOT"}, lines)
+
+	err = Generate(context.Background(), &out, GenerateArgs{Dir: dir, Slots: 2, Now: "2030-01-08T09:00:00Z"})
+	assert.Error(t, err)
+}
+
+func TestGenerateJSON(t *testing.T) {
+	dir := t.TempDir()
+	input := "[[deadlines]]\nname = \"essay\"\nminutesRemaining = 25\ndeadline = 2030-01-07T10:00:00Z\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(input), 0644))
+
+	var out bytes.Buffer
+	err := Generate(context.Background(), &out, GenerateArgs{Dir: dir, Slots: 1, Now: "2030-01-07T09:30:00Z", Format: "json"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"start": "2030-01-07T09:30:00Z", "end": "2030-01-07T09:55:00Z", "kind": "deadline", "name": "essay", "source": "`+filepath.Join(dir, ".at.toml")+`"},
+		{"start": "2030-01-07T09:55:00Z", "end": "2030-01-07T10:00:00Z", "kind": "break"}
+	]`, out.String())
+
+	// the timetable is saved for planning again
+	state, err := readState(filepath.Join(dir, ".at.state.json"))
+	assert.NoError(t, err)
+	if assert.NotNil(t, state) {
+		assert.Equal(t, []backend.StateSlot{{Start: time.Date(2030, 1, 7, 9, 30, 0, 0, time.UTC), End: time.Date(2030, 1, 7, 10, 0, 0, 0, time.UTC), Deadline: "essay", Source: filepath.Join(dir, ".at.toml")}}, state.Work)
+	}
+
+	err = Generate(context.Background(), &out, GenerateArgs{Dir: dir, Format: "yaml"})
+	assert.Error(t, err)
+}
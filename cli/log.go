@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// LogArgs holds the command-line arguments for logging work done on a deadline
+type LogArgs struct {
+	Dir string
+	// LogFile is the work log to append to, defaulting to .at.log.toml at the top of the tree
+	LogFile string
+	Name    string
+	Minutes float64
+	// Done marks the deadline as finished, however much work was left on it
+	Done bool
+	// Now is when the work was done, or empty for the current time
+	Now string
+}
+
+// LogWork takes the minutes worked off the deadline in the .at.toml it is set in, taking the
+// deadline out once there is no work left on it or it is done, and appends an entry to the
+// work log, writing what was logged to w
+func LogWork(w io.Writer, args LogArgs) error {
+	if args.Minutes < 0 || (args.Minutes == 0 && !args.Done) {
+		return fmt.Errorf("could not log %v minutes, as it must be positive", args.Minutes)
+	}
+	now := time.Now()
+	if args.Now != "" {
+		parsed, err := parseTime(args.Now)
+		if err != nil {
+			return fmt.Errorf("could not parse now: %w", err)
+		}
+		now = parsed
+	}
+	deadline, err := backend.FindDeadline(args.Dir, args.Name)
+	if err != nil {
+		return fmt.Errorf("could not log work: %w", err)
+	}
+	remaining := deadline.MinutesRemaining - args.Minutes
+	done := args.Done || remaining <= 0
+	if done {
+		remaining = 0
+	}
+	if err := editDeadline(deadline.Source, args.Name, remaining); err != nil {
+		return err
+	}
+	logFile := args.LogFile
+	if logFile == "" {
+		logFile = filepath.Join(args.Dir, ".at.log.toml")
+	}
+	entry := types.LogEntry{Deadline: args.Name, Time: now, Minutes: args.Minutes, MinutesRemaining: remaining, Done: done, Source: deadline.Source}
+	if err := appendToml(logFile, struct {
+		Log []types.LogEntry `toml:"log"`
+	}{[]types.LogEntry{entry}}); err != nil {
+		return err
+	}
+	if done {
+		_, err = fmt.Fprintf(w, "%s is done, and was taken out of %s\n", args.Name, deadline.Source)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "logged %v minutes on %s, leaving %v\n", args.Minutes, args.Name, remaining)
+	return err
+}
+
+// tomlHeader matches the header of a table or an array of tables, such as [[deadlines]]
+var tomlHeader = regexp.MustCompile(`^\s*(\[\[?)\s*([^\[\]]+?)\s*\]\]?\s*(#.*)?$`)
+
+// minutesRemainingKey matches the line setting a deadline's minutesRemaining
+// along with any comment after it
+var minutesRemainingKey = regexp.MustCompile(`^(\s*)minutesRemaining\s*=[^#]*?(\s*#.*)?\n?$`)
+
+// editDeadline finds the first [[deadlines]] table called name in the toml file and sets its
+// minutesRemaining to remaining, or takes the table out if remaining is 0, leaving the rest of
+// the file as it was
+func editDeadline(fileName string, name string, remaining float64) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", fileName, err)
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	start := -1
+	for i := 0; i <= len(lines); i++ {
+		var header []string
+		if i < len(lines) {
+			header = tomlHeader.FindStringSubmatch(lines[i])
+			if header == nil {
+				continue
+			}
+		}
+		if start >= 0 {
+			var deadline types.Deadline
+			if err := toml.Unmarshal([]byte(strings.Join(lines[start+1:i], "")), &deadline); err == nil && deadline.Name == name {
+				return os.WriteFile(fileName, []byte(strings.Join(editTable(lines, start, i, remaining), "")), 0644)
+			}
+		}
+		start = -1
+		if header != nil && header[1] == "[[" && header[2] == "deadlines" {
+			start = i
+		}
+	}
+	return fmt.Errorf("could not find the deadlines table for %s in %s", name, fileName)
+}
+
+// editTable sets minutesRemaining in the table from the line at start up to the one at end,
+// or takes it out if remaining is 0
+func editTable(lines []string, start int, end int, remaining float64) []string {
+	if remaining > 0 {
+		value := strconv.FormatFloat(remaining, 'f', -1, 64)
+		for i := start + 1; i < end; i++ {
+			if match := minutesRemainingKey.FindStringSubmatch(lines[i]); match != nil {
+				lines[i] = match[1] + "minutesRemaining = " + value + match[2] + "\n"
+				return lines
+			}
+		}
+		added := append([]string{lines[start], "minutesRemaining = " + value + "\n"}, lines[start+1:]...)
+		return append(lines[:start:start], added...)
+	}
+	header := start
+	// comments just before a table's header belong with it
+	for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+		start--
+	}
+	for end < len(lines) && end > header+1 {
+		trimmed := strings.TrimSpace(lines[end-1])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		end--
+	}
+	kept := append(lines[:start:start], lines[end:]...)
+	// don't leave two blank lines, or one at the end, where the table was
+	for start < len(kept) && strings.TrimSpace(kept[start]) == "" && (start == 0 || strings.TrimSpace(kept[start-1]) == "") {
+		kept = append(kept[:start], kept[start+1:]...)
+	}
+	for start == len(kept) && start > 0 && strings.TrimSpace(kept[start-1]) == "" {
+		kept = kept[:start-1]
+		start--
+	}
+	return kept
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mhbardsley/auto-timetable/backend"
+	"github.com/mhbardsley/auto-timetable/types"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogWork(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "uni"), 0755))
+	fileName := filepath.Join(dir, "uni", ".at.toml")
+	existing := "# coursework\n\n# short\n[[deadlines]]\nname = \"essay\"\nminutesRemaining = 100.0 # roughly\ndeadline = 2030-01-10T12:00:00Z\n\n" +
+		"# the big one\n[[deadlines]]\nname = 'report'\nminutesRemaining = 50\ndeadline = 2030-01-12T12:00:00Z\n"
+	assert.NoError(t, os.WriteFile(fileName, []byte(existing), 0644))
+
+	var out bytes.Buffer
+	err := LogWork(&out, LogArgs{Dir: dir, Name: "essay", Minutes: 40, Now: "2030-01-02T10:00:00Z"})
+	assert.NoError(t, err)
+	assert.Equal(t, "logged 40 minutes on essay, leaving 60\n", out.String())
+	written, err := os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "# coursework\n\n# short\n[[deadlines]]\nname = \"essay\"\nminutesRemaining = 60 # roughly\ndeadline = 2030-01-10T12:00:00Z\n\n"+
+		"# the big one\n[[deadlines]]\nname = 'report'\nminutesRemaining = 50\ndeadline = 2030-01-12T12:00:00Z\n", string(written))
+
+	// working off the rest takes the deadline out, along with its comment
+	err = LogWork(&out, LogArgs{Dir: dir, Name: "essay", Minutes: 75, Now: "2030-01-03T10:00:00Z"})
+	assert.NoError(t, err)
+	written, err = os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "# coursework\n\n# the big one\n[[deadlines]]\nname = 'report'\nminutesRemaining = 50\ndeadline = 2030-01-12T12:00:00Z\n", string(written))
+
+	err = LogWork(&out, LogArgs{Dir: dir, Name: "report", Done: true, Now: "2030-01-04T10:00:00Z"})
+	assert.NoError(t, err)
+	written, err = os.ReadFile(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "# coursework\n", string(written))
+
+	err = LogWork(&out, LogArgs{Dir: dir, Name: "report", Minutes: 10})
+	assert.ErrorIs(t, err, backend.ErrDeadlineNotFound)
+	err = LogWork(&out, LogArgs{Dir: dir, Name: "report", Minutes: -10})
+	assert.Error(t, err)
+
+	// every entry is in the work log at the top of the tree
+	logged, err := os.ReadFile(filepath.Join(dir, ".at.log.toml"))
+	assert.NoError(t, err)
+	var workLog struct {
+		Log []types.LogEntry `toml:"log"`
+	}
+	assert.NoError(t, toml.Unmarshal(logged, &workLog))
+	assert.Len(t, workLog.Log, 3)
+	assert.Equal(t, 60.0, workLog.Log[0].MinutesRemaining)
+	assert.Equal(t, fileName, workLog.Log[0].Source)
+	assert.True(t, workLog.Log[1].Done)
+	assert.Equal(t, 75.0, workLog.Log[1].Minutes)
+	assert.True(t, workLog.Log[2].Done)
+}
+
+func TestLogWorkDependedOn(t *testing.T) {
+	dir := t.TempDir()
+	existing := "[[deadlines]]\nname = \"report\"\nminutesRemaining = 50\ndeadline = 2030-01-10T12:00:00Z\n\n" +
+		"[[deadlines]]\nname = \"slides\"\nminutesRemaining = 50\ndeadline = 2030-01-12T12:00:00Z\ndependsOn = [\"report\"]\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".at.toml"), []byte(existing), 0644))
+
+	// the slides can still depend on the report once it is done and taken out
+	var out bytes.Buffer
+	assert.NoError(t, LogWork(&out, LogArgs{Dir: dir, Name: "report", Done: true, Now: "2030-01-02T10:00:00Z"}))
+	in, err := backend.Load(dir)
+	assert.NoError(t, err)
+	assert.Len(t, in.Deadlines, 1)
+	assert.Equal(t, []string{"report"}, in.Done)
+
+	// as can a deadline added after
+	assert.NoError(t, AddDeadline(DeadlineArgs{Dir: dir, Name: "talk", MinutesRemaining: 25, Deadline: "2030-01-12T12:00:00Z", DependsOn: []string{"report"}, Now: "2030-01-02T10:00:00Z"}))
+}
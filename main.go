@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/mhbardsley/auto-timetable/backend"
 	"github.com/mhbardsley/auto-timetable/cli"
@@ -16,7 +18,28 @@ func main() {
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exit codes distinguishing why a command failed
+const (
+	exitError        = 1
+	exitInvalidInput = 2
+	exitInfeasible   = 3
+)
+
+// exitCode picks the exit code for an error returned by a command
+func exitCode(err error) int {
+	var validationErr *backend.ValidationError
+	var infeasibleErr *backend.InfeasibleError
+	switch {
+	case errors.As(err, &validationErr):
+		return exitInvalidInput
+	case errors.As(err, &infeasibleErr):
+		return exitInfeasible
+	default:
+		return exitError
 	}
 }
 
@@ -25,46 +48,122 @@ func makeRootCommand() *cobra.Command {
 		Use:   "auto-timetable",
 		Short: "Time management program",
 		Long:  `Time management program that allows you to generate a timetbale.`,
+		// main reports errors itself
+		SilenceErrors: true,
+		SilenceUsage:  true,
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Println("Usage: auto-timetable <subcommand> [flags]")
 			fmt.Println("subcommands:")
 			fmt.Println("  generate - generate a timetable")
+			fmt.Println("  check - check there is time for every deadline")
 			fmt.Println("  add - add an event or deadline")
+			fmt.Println("  log - log work done on a deadline")
+			fmt.Println("  done - mark a deadline as done")
+			fmt.Println("  diff - compare two timetables")
 			fmt.Println("  help - display this help")
 		},
 	}
 
 	rootCmd.AddCommand(makeGenerateCommand())
+	rootCmd.AddCommand(makeCheckCommand())
 	rootCmd.AddCommand(makeAddCommand())
+	rootCmd.AddCommand(makeLogCommand())
+	rootCmd.AddCommand(makeDoneCommand())
+	rootCmd.AddCommand(makeDiffCommand())
 
 	return rootCmd
 }
 
-
 func makeGenerateCommand() *cobra.Command {
-	var dirName string
-	var noOfSlots int
+	var generateArgs cli.GenerateArgs
 	var threshold float64
 
 	generateCmd := &cobra.Command{
 		Use:   "generate",
 		Short: "Generate a timetable",
 		Long:  `Generate a timetable from input data`,
-		Run: func(cmd *cobra.Command, args []string) {
-			inputData := backend.GetInput(&dirName, noOfSlots)
-			backend.GenerateTimetable(inputData, threshold)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.Generate(cmd.Context(), os.Stdout, generateArgs)
 		},
 	}
 
-	generateCmd.Flags().StringVarP(&dirName, "dir", "d", "toplevel/", "Toplevel directory")
-	generateCmd.Flags().IntVarP(&noOfSlots, "slots", "s", 48, "The number of slots to display")
+	addGenerateFlags(generateCmd, &generateArgs)
+	generateCmd.Flags().IntVarP(&generateArgs.Slots, "slots", "s", 48, "The number of slots to display")
+	generateCmd.Flags().StringVar(&generateArgs.Format, "format", "text", fmt.Sprintf("Output format, one of %v", cli.Formats))
+	generateCmd.Flags().BoolVar(&generateArgs.ICSBreaks, "icsBreaks", false, "Include breaks as events in the ics format")
 	generateCmd.Flags().Float64VarP(&threshold, "threshold", "r", 0.04, "Repopulation threshold")
+	_ = generateCmd.Flags().MarkDeprecated("threshold", "it has no effect")
 
 	return generateCmd
 }
 
+// addGenerateFlags adds the flags that decide the timetable generated, shared by the commands
+// that generate one
+func addGenerateFlags(cmd *cobra.Command, generateArgs *cli.GenerateArgs) {
+	cmd.Flags().StringVarP(&generateArgs.Dir, "dir", "d", "toplevel/", "Toplevel directory")
+	cmd.Flags().StringSliceVar(&generateArgs.ICS, "ics", nil, "iCalendar files to read events from, as well as any .ics files in the toplevel directory")
+	cmd.Flags().StringVar(&generateArgs.Now, "now", "", "Time to plan from (defaults to the current time)")
+	cmd.Flags().Var(&seedValue{seed: &generateArgs.Seed}, "seed", "Seed for the random assignment, as printed by a previous run (defaults to one derived from the time planned from)")
+	cmd.Flags().IntVar(&generateArgs.SlotMinutes, "slotMinutes", 0, "Length of a slot in minutes (defaults to the settings, or work plus break)")
+	cmd.Flags().IntVar(&generateArgs.WorkMinutes, "workMinutes", 0, "Minutes of work on a deadline per slot (defaults to the settings, or 25)")
+	cmd.Flags().IntVar(&generateArgs.BreakMinutes, "breakMinutes", 0, "Minutes of break after working on a deadline (defaults to the settings, or 5)")
+	cmd.Flags().StringVar(&generateArgs.Strategy, "strategy", string(backend.Stochastic), fmt.Sprintf("How deadlines are assigned to slots, one of %v", backend.Strategies()))
+	cmd.Flags().IntVar(&generateArgs.MaxAttempts, "maxAttempts", backend.DefaultMaxAttempts, "Passes the stochastic strategy makes before falling back to spread")
+	cmd.Flags().DurationVar(&generateArgs.Timeout, "timeout", backend.DefaultTimeout, "Time the stochastic strategy takes before falling back to spread")
+	cmd.Flags().BoolVar(&generateArgs.BestEffort, "best-effort", false, "Generate a timetable even if there is too little time for every deadline, reporting the work left undone")
+	cmd.Flags().StringVar(&generateArgs.Policy, "policy", string(backend.EarliestDeadlineFirst), fmt.Sprintf("How too little time is shared out with --best-effort, one of %v", backend.Policies()))
+	cmd.Flags().Float64Var(&generateArgs.SwitchPenalty, "switchPenalty", 0, "How much less likely work is to switch to another deadline, from 0 to 1 (defaults to the settings, or 0)")
+	cmd.Flags().IntVar(&generateArgs.MaxMinutesPerDay, "maxMinutesPerDay", 0, "Most minutes of work on deadlines to plan in a day (defaults to the settings, or no limit)")
+	cmd.Flags().StringVar(&generateArgs.State, "state", "", "Where the timetable is saved for planning again (defaults to .at.state.json in the toplevel directory)")
+	cmd.Flags().DurationVar(&generateArgs.Freeze, "freeze", 0, "How far ahead to keep the work planned by the saved timetable (defaults to the settings, or none)")
+	cmd.Flags().BoolVar(&generateArgs.Fresh, "fresh", false, "Plan afresh, without keeping to the saved timetable")
+}
+
+// seedValue is the --seed flag, which leaves the seed unset unless given, so that any seed,
+// 0 included, can be given
+type seedValue struct {
+	seed **int64
+}
+
+func (v *seedValue) String() string {
+	if *v.seed == nil {
+		return ""
+	}
+	return strconv.FormatInt(**v.seed, 10)
+}
+
+func (v *seedValue) Set(s string) error {
+	seed, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v.seed = &seed
+	return nil
+}
+
+func (v *seedValue) Type() string {
+	return "int"
+}
+
+func makeCheckCommand() *cobra.Command {
+	var checkArgs cli.CheckArgs
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check there is time for every deadline",
+		Long:  `Report the work due by each deadline against the free slots before it, with suggestions for making room if there is too little, for the timetable generate would plan`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.Check(os.Stdout, checkArgs)
+		},
+	}
+
+	addGenerateFlags(checkCmd, &checkArgs.Generate)
+
+	return checkCmd
+}
+
 func makeAddCommand() *cobra.Command {
-	var dirName string
+	var dirName, fileName, nowStr string
 
 	addCmd := &cobra.Command{
 		Use:   "add",
@@ -76,12 +175,12 @@ func makeAddCommand() *cobra.Command {
 			fmt.Println("  event - add an event")
 			fmt.Println("  deadline - add a deadline")
 			fmt.Println("  help - display this help")
-
-			fmt.Println(dirName)
 		},
 	}
 
-	addCmd.PersistentFlags().StringVarP(&dirName, "file", "f", "input.json", "The input's filename")
+	addCmd.PersistentFlags().StringVar(&dirName, "dir", "toplevel/", "Toplevel directory, checked for clashes")
+	addCmd.PersistentFlags().StringVarP(&fileName, "file", "f", "", "The .at.toml to add to (defaults to the one in the toplevel directory)")
+	addCmd.PersistentFlags().StringVar(&nowStr, "now", "", "Time to check from (defaults to the current time)")
 
 	addCmd.AddCommand(makeAddEventCommand())
 	addCmd.AddCommand(makeAddDeadlineCommand())
@@ -96,38 +195,169 @@ func makeAddEventCommand() *cobra.Command {
 		Use:   "event",
 		Short: "Add an event",
 		Long:  `Add an event to the existing timetable`,
-		Run: func(cmd *cobra.Command, args []string) {
-			dirName, _ := cmd.Flags().GetString("file")
-			// TODO: send via a struct
-			cli.AddEvent(&dirName)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dirName, _ := cmd.Flags().GetString("dir")
+			fileName, _ := cmd.Flags().GetString("file")
+			nowStr, _ := cmd.Flags().GetString("now")
+			return cli.AddEvent(cli.EventArgs{
+				Dir:       dirName,
+				File:      fileName,
+				Name:      eventName,
+				StartTime: startTimeStr,
+				EndTime:   endTimeStr,
+				Now:       nowStr,
+			})
 		},
 	}
 
 	eventCmd.Flags().StringVarP(&eventName, "name", "n", "", "Name of the event")
 	eventCmd.Flags().StringVarP(&startTimeStr, "startTime", "s", "", "Start time")
 	eventCmd.Flags().StringVarP(&endTimeStr, "endTime", "e", "", "End time")
+	_ = eventCmd.MarkFlagRequired("name")
+	_ = eventCmd.MarkFlagRequired("startTime")
+	_ = eventCmd.MarkFlagRequired("endTime")
 
 	return eventCmd
 }
 
 func makeAddDeadlineCommand() *cobra.Command {
-	var deadlineName, deadlineStr string
+	var deadlineName, deadlineStr, startTimeStr string
 	var minutesRemaining float64
+	var priority int
+	var dependsOn []string
+	var minBlock, maxBlock, maxMinutesPerDay int
+	var force bool
 
 	deadlineCmd := &cobra.Command{
 		Use:   "deadline",
 		Short: "Add a deadline",
-		Long:  `Add a deadline to the existing timetable`,
-		Run: func(cmd *cobra.Command, args []string) {
-			dirName, _ := cmd.Flags().GetString("file")
-			// TODO: send via a struct
-			cli.AddDeadline(&dirName)
+		Long:  `Add a deadline to the existing timetable, provided there is still time to meet every deadline`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dirName, _ := cmd.Flags().GetString("dir")
+			fileName, _ := cmd.Flags().GetString("file")
+			nowStr, _ := cmd.Flags().GetString("now")
+			return cli.AddDeadline(cli.DeadlineArgs{
+				Dir:              dirName,
+				File:             fileName,
+				Name:             deadlineName,
+				MinutesRemaining: minutesRemaining,
+				Deadline:         deadlineStr,
+				StartTime:        startTimeStr,
+				DependsOn:        dependsOn,
+				MinBlock:         minBlock,
+				MaxBlock:         maxBlock,
+				MaxMinutesPerDay: maxMinutesPerDay,
+				Priority:         priority,
+				Force:            force,
+				Now:              nowStr,
+			})
 		},
 	}
 
 	deadlineCmd.Flags().StringVarP(&deadlineName, "name", "n", "", "Name of the deadline")
 	deadlineCmd.Flags().Float64VarP(&minutesRemaining, "minutesRemaining", "m", 25.0, "Time to complete deadline")
 	deadlineCmd.Flags().StringVarP(&deadlineStr, "deadline", "d", "", "Time of the deadline")
+	deadlineCmd.Flags().StringVarP(&startTimeStr, "startTime", "s", "", "Earliest time the deadline can be worked on")
+	deadlineCmd.Flags().StringSliceVar(&dependsOn, "dependsOn", nil, "Names of deadlines that have to be done first")
+	deadlineCmd.Flags().IntVar(&minBlock, "minBlock", 0, "Fewest slots in a row to work on the deadline for")
+	deadlineCmd.Flags().IntVar(&maxBlock, "maxBlock", 0, "Most slots in a row to work on the deadline for")
+	deadlineCmd.Flags().IntVar(&maxMinutesPerDay, "maxMinutesPerDay", 0, "Most minutes of work on the deadline to plan in a day")
+	deadlineCmd.Flags().IntVar(&priority, "priority", 0, "Priority of the deadline, where higher is more important")
+	deadlineCmd.Flags().BoolVar(&force, "force", false, "Add the deadline even if there is too little time to meet every deadline")
+	_ = deadlineCmd.MarkFlagRequired("name")
+	_ = deadlineCmd.MarkFlagRequired("deadline")
 
 	return deadlineCmd
-}
\ No newline at end of file
+}
+
+func makeLogCommand() *cobra.Command {
+	var dirName, logFile, nowStr string
+
+	logCmd := &cobra.Command{
+		Use:   "log <deadline> <minutes>",
+		Short: "Log work done on a deadline",
+		Long:  `Take the minutes worked off a deadline's minutesRemaining, taking it out once there is none left, and record the work in the work log`,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			minutes, err := strconv.ParseFloat(args[1], 64)
+			if err != nil {
+				return fmt.Errorf("could not parse minutes: %w", err)
+			}
+			return cli.LogWork(os.Stdout, cli.LogArgs{
+				Dir:     dirName,
+				LogFile: logFile,
+				Name:    args[0],
+				Minutes: minutes,
+				Now:     nowStr,
+			})
+		},
+	}
+
+	addLogFlags(logCmd, &dirName, &logFile, &nowStr)
+
+	return logCmd
+}
+
+func makeDoneCommand() *cobra.Command {
+	var dirName, logFile, nowStr string
+
+	doneCmd := &cobra.Command{
+		Use:   "done <deadline> [minutes]",
+		Short: "Mark a deadline as done",
+		Long:  `Take a deadline out of its .at.toml, however much work was left on it, and record it in the work log along with any minutes worked`,
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var minutes float64
+			if len(args) > 1 {
+				var err error
+				if minutes, err = strconv.ParseFloat(args[1], 64); err != nil {
+					return fmt.Errorf("could not parse minutes: %w", err)
+				}
+			}
+			return cli.LogWork(os.Stdout, cli.LogArgs{
+				Dir:     dirName,
+				LogFile: logFile,
+				Name:    args[0],
+				Minutes: minutes,
+				Done:    true,
+				Now:     nowStr,
+			})
+		},
+	}
+
+	addLogFlags(doneCmd, &dirName, &logFile, &nowStr)
+
+	return doneCmd
+}
+
+func makeDiffCommand() *cobra.Command {
+	var diffArgs cli.DiffArgs
+
+	diffCmd := &cobra.Command{
+		Use:   "diff [before] [after]",
+		Short: "Compare two timetables",
+		Long:  `Report the blocks of work on each deadline that moved, were added or were removed between two timetables, saved by generate or written by it in the json format, or between the saved timetable and a new one, generated as generate would but not saved`,
+		Args:  cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				diffArgs.Before = args[0]
+			}
+			if len(args) > 1 {
+				diffArgs.After = args[1]
+			}
+			return cli.Diff(cmd.Context(), os.Stdout, diffArgs)
+		},
+	}
+
+	addGenerateFlags(diffCmd, &diffArgs.Generate)
+	diffCmd.Flags().StringVar(&diffArgs.Format, "format", "text", "Output format, one of [text json]")
+
+	return diffCmd
+}
+
+// addLogFlags adds the flags shared by the commands that log work
+func addLogFlags(cmd *cobra.Command, dirName, logFile, nowStr *string) {
+	cmd.Flags().StringVarP(dirName, "dir", "d", "toplevel/", "Toplevel directory")
+	cmd.Flags().StringVar(logFile, "logFile", "", "The work log to append to (defaults to .at.log.toml in the toplevel directory)")
+	cmd.Flags().StringVar(nowStr, "now", "", "Time the work was done (defaults to the current time)")
+}
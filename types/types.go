@@ -3,18 +3,105 @@ package types
 import "time"
 
 type Event struct {
-	Name       string    `json:"name,omitempty" toml:"name"`
-	StartTime  time.Time `json:"startTime" toml:"startTime"`
-	EndTime    time.Time `json:"endTime" toml:"endTime"`
+	Name      string    `json:"name,omitempty" toml:"name"`
+	StartTime time.Time `json:"startTime" toml:"startTime"`
+	EndTime   time.Time `json:"endTime" toml:"endTime"`
+	// Recurrence, if set, repeats the event from its start time
+	Recurrence *Recurrence `json:"recurrence,omitempty" toml:"recurrence,omitempty"`
+}
+
+// Recurrence says how an event repeats, following the parts of an iCalendar RRULE
+type Recurrence struct {
+	// Frequency is daily, weekly, monthly or yearly
+	Frequency string `json:"frequency" toml:"frequency"`
+	// Interval is the number of days, weeks, months or years between repeats, defaulting to 1
+	Interval int `json:"interval,omitempty" toml:"interval,omitempty"`
+	// ByDay are the days (MO, TU, ...) a weekly event happens on, defaulting to the day it
+	// starts, or that a daily event is limited to
+	ByDay []string `json:"byDay,omitempty" toml:"byDay,omitempty"`
+	// Until and Count, if set, end the repeats at the last one starting by Until, or after Count
+	Until time.Time `json:"until,omitempty" toml:"until,omitempty"`
+	Count int       `json:"count,omitempty" toml:"count,omitempty"`
+	// Exceptions are the start times of repeats that do not happen
+	Exceptions []time.Time `json:"exceptions,omitempty" toml:"exceptions,omitempty"`
 }
 
 type Deadline struct {
 	Name             string    `json:"name" toml:"name"`
 	MinutesRemaining float64   `json:"minutesRemaining" toml:"minutesRemaining"`
-	DeadlineTime         time.Time `json:"deadline" toml:"deadline"`
+	DeadlineTime     time.Time `json:"deadline" toml:"deadline"`
+	// StartTime, if set, is the earliest the deadline can be worked on
+	StartTime *time.Time `json:"startTime,omitempty" toml:"startTime,omitempty"`
+	// DependsOn names the deadlines whose work has to be done before this one's can start
+	DependsOn []string `json:"dependsOn,omitempty" toml:"dependsOn,omitempty"`
+	// MinBlock and MaxBlock, if set, bound the number of slots in a row spent on the deadline
+	MinBlock int `json:"minBlock,omitempty" toml:"minBlock,omitempty"`
+	MaxBlock int `json:"maxBlock,omitempty" toml:"maxBlock,omitempty"`
+	// MaxMinutesPerDay, if set, is the most work on the deadline planned in a day
+	MaxMinutesPerDay int `json:"maxMinutesPerDay,omitempty" toml:"maxMinutesPerDay,omitempty"`
+	// Priority favours a deadline when slots are shared out, each step up doubling its weight,
+	// and ranks deadlines due together or when there is too little time for all of them
+	Priority int `json:"priority,omitempty" toml:"priority,omitempty"`
 }
 
 type Periodic struct {
-	Name             string    `json:"name" toml:"name"`
-	Probability float64   `json:"frequency" toml:"probability"`
-}
\ No newline at end of file
+	Name        string  `json:"name" toml:"name"`
+	Probability float64 `json:"frequency" toml:"probability"`
+	// DurationMinutes, if set, makes the periodic a habit that takes up that long in free
+	// slots, PerDay or PerWeek times, instead of happening at random
+	DurationMinutes int `json:"durationMinutes,omitempty" toml:"durationMinutes,omitempty"`
+	PerDay          int `json:"perDay,omitempty" toml:"perDay,omitempty"`
+	PerWeek         int `json:"perWeek,omitempty" toml:"perWeek,omitempty"`
+	// Windows, if set, are the ranges of the day such as "07:00-09:00" a habit is kept to,
+	// otherwise it is kept to the available times
+	Windows []string `json:"windows,omitempty" toml:"windows,omitempty"`
+	// MinSpacingMinutes is the least time between the starts of a habit's occurrences
+	MinSpacingMinutes int `json:"minSpacingMinutes,omitempty" toml:"minSpacingMinutes,omitempty"`
+}
+
+// LogEntry records work done on a deadline, in the work log
+type LogEntry struct {
+	Deadline string    `json:"deadline" toml:"deadline"`
+	Time     time.Time `json:"time" toml:"time"`
+	Minutes  float64   `json:"minutes" toml:"minutes"`
+	// MinutesRemaining is the work left on the deadline once this was done
+	MinutesRemaining float64 `json:"minutesRemaining" toml:"minutesRemaining"`
+	// Done marks the deadline as finished, so it was taken out of its .at.toml
+	Done bool `json:"done,omitempty" toml:"done,omitempty"`
+	// Source is the .at.toml the deadline was set in
+	Source string `json:"source" toml:"source"`
+}
+
+type Settings struct {
+	SlotMinutes  int `json:"slotMinutes" toml:"slotMinutes"`
+	WorkMinutes  int `json:"workMinutes" toml:"workMinutes"`
+	BreakMinutes int `json:"breakMinutes" toml:"breakMinutes"`
+	// SwitchPenalty, between 0 and 1, is how much less likely work is to switch to another
+	// deadline rather than carry on with the last
+	SwitchPenalty float64 `json:"switchPenalty" toml:"switchPenalty"`
+	// MaxMinutesPerDay, if set, is the most work on deadlines planned in a day
+	MaxMinutesPerDay int `json:"maxMinutesPerDay" toml:"maxMinutesPerDay"`
+	// FreezeMinutes is how far ahead work planned by the last timetable is kept when planning again
+	FreezeMinutes int `json:"freezeMinutes" toml:"freezeMinutes"`
+}
+
+// Availability is when deadlines can be worked on, as ranges of the day such as "09:00-17:30"
+type Availability struct {
+	// Default is used for days of the week not given
+	Default   []string `json:"default,omitempty" toml:"default,omitempty"`
+	Monday    []string `json:"monday,omitempty" toml:"monday,omitempty"`
+	Tuesday   []string `json:"tuesday,omitempty" toml:"tuesday,omitempty"`
+	Wednesday []string `json:"wednesday,omitempty" toml:"wednesday,omitempty"`
+	Thursday  []string `json:"thursday,omitempty" toml:"thursday,omitempty"`
+	Friday    []string `json:"friday,omitempty" toml:"friday,omitempty"`
+	Saturday  []string `json:"saturday,omitempty" toml:"saturday,omitempty"`
+	Sunday    []string `json:"sunday,omitempty" toml:"sunday,omitempty"`
+	// Overrides replace the ranges for particular dates, such as holidays
+	Overrides []AvailabilityOverride `json:"overrides,omitempty" toml:"overrides,omitempty"`
+}
+
+// AvailabilityOverride gives the ranges for a particular date, with none meaning the whole day off
+type AvailabilityOverride struct {
+	Date  time.Time `json:"date" toml:"date"`
+	Hours []string  `json:"hours" toml:"hours"`
+}